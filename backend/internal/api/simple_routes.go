@@ -2,46 +2,231 @@ package api
 
 import (
 	"database/sql"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/config"
 	"canvas-designer-backend/internal/handlers"
+	"canvas-designer-backend/internal/jobs"
+	"canvas-designer-backend/internal/mailer"
 	"canvas-designer-backend/internal/middleware"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/ratelimit"
+	"canvas-designer-backend/internal/remote"
+	"canvas-designer-backend/internal/scanner"
+	"canvas-designer-backend/internal/storage"
 )
 
-func SetupSimpleRoutes(r *gin.Engine, db *sql.DB) {
+func SetupSimpleRoutes(r *gin.Engine, db *sql.DB, cfg *config.Config) {
+	// Track login/register attempts in Postgres when running several instances
+	var attemptStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.AuthAttemptStore == "postgres" {
+		attemptStore = ratelimit.NewPostgresStore(db)
+	}
+
+	// Write mail to the log unless an SMTP server is configured
+	var mail mailer.Mailer = mailer.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+
+	// Keep uploads and exports on local disk unless an object store is configured
+	signer := storage.NewURLSigner(cfg.StorageSigningKey)
+	var store storage.Storage = storage.NewLocalStorage(cfg.StorageLocalDir, "", signer)
+	if cfg.StorageDriver == "s3" {
+		s3Store, err := storage.NewS3Storage(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure S3 storage:", err)
+		}
+		store = s3Store
+	}
+
+	// Clear uploads without scanning unless a ClamAV daemon is configured
+	var scan scanner.Scanner = scanner.NewNopScanner()
+	if cfg.Scanner == "clamav" {
+		scan = scanner.NewClamAVScanner(cfg.ClamAVAddress, 30*time.Second)
+	}
+
+	// Fetch URL imports with the same 10MB limit as single uploads
+	fetcher := remote.NewFetcher(10*1024*1024, 15*time.Second, cfg.ImportAllowPrivateNetworks)
+
 	// Initialize handlers
-	authHandler := handlers.NewSimpleAuthHandler(db)
+	authHandler := handlers.NewSimpleAuthHandler(db, attemptStore, mail, store, cfg.AppURL)
 	designHandler := handlers.NewSimpleDesignHandler(db)
-	templateHandler := handlers.NewSimpleTemplateHandler(db)
-	uploadHandler := handlers.NewUploadHandler()
+	templateHandler := handlers.NewSimpleTemplateHandler(db, store)
+	uploadHandler := handlers.NewUploadHandler(db, store, scan, fetcher, cfg.StorageQuotaBytes, cfg.UploadMaxBytes)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, store)
+	generationHandler := handlers.NewTemplateGenerationHandler(db, store)
+	assetHandler := handlers.NewAssetHandler(db, store, signer, cfg.StorageQuotaBytes)
+	fontHandler := handlers.NewFontHandler(db, store, scan, cfg.StorageQuotaBytes)
+	brandKitHandler := handlers.NewBrandKitHandler(db)
+	teamHandler := handlers.NewTeamHandler(db, mail, cfg.AppURL)
+
+	// Retry scanning uploads that are still quarantined, drop abandoned
+	// resumable uploads and purge accounts whose deletion grace period has
+	// elapsed
+	jobs.StartQuarantineRescan(uploadHandler, 5*time.Minute)
+	jobs.StartUploadExpiry(uploadHandler, time.Hour)
+	jobs.StartAccountPurge(authHandler, time.Hour)
+
+	// Generation jobs run in this process; any still pending were cut off
+	generationHandler.FailInterruptedJobs()
+
+	// Uploaded files; private assets need the owner's credentials or a signed URL
+	r.GET("/uploads/*path", middleware.OptionalAuthMiddleware(db), assetHandler.ServeUpload)
+	r.HEAD("/uploads/*path", middleware.OptionalAuthMiddleware(db), assetHandler.ServeUpload)
 
 	// Public routes
 	public := r.Group("/api")
 	{
 		public.POST("/register", authHandler.Register)
 		public.POST("/login", authHandler.Login)
-		public.GET("/templates", templateHandler.GetTemplates)
-		public.GET("/templates/:id", templateHandler.GetTemplate)
+		public.POST("/verify-email", authHandler.VerifyEmail)
+		public.GET("/templates", middleware.OptionalAuthMiddleware(db), templateHandler.GetTemplates)
+		public.GET("/templates/categories", middleware.OptionalAuthMiddleware(db), templateHandler.GetCategories)
+		public.GET("/templates/:id", middleware.OptionalAuthMiddleware(db), templateHandler.GetTemplate)
+		public.GET("/templates/:id/variables", middleware.OptionalAuthMiddleware(db), generationHandler.GetTemplateVariables)
 	}
 
 	// Protected routes
 	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(db))
 	{
 		// User routes
 		protected.GET("/profile", authHandler.GetProfile)
-		protected.PUT("/profile", authHandler.UpdateProfile)
+		protected.PUT("/profile", middleware.SessionOnly(), authHandler.UpdateProfile)
+		protected.PUT("/profile/password", middleware.SessionOnly(), authHandler.ChangePassword)
+		protected.PUT("/profile/email", middleware.SessionOnly(), authHandler.ChangeEmail)
+		protected.DELETE("/profile", middleware.SessionOnly(), authHandler.DeleteAccount)
+		protected.POST("/profile/restore", middleware.SessionOnly(), authHandler.CancelAccountDeletion)
+		protected.GET("/profile/export", middleware.SessionOnly(), authHandler.ExportAccount)
+		protected.GET("/profile/storage", assetHandler.GetStorageUsage)
+
+		// API key routes
+		protected.GET("/api-keys", middleware.SessionOnly(), apiKeyHandler.GetAPIKeys)
+		protected.POST("/api-keys", middleware.SessionOnly(), apiKeyHandler.CreateAPIKey)
+		protected.DELETE("/api-keys/:id", middleware.SessionOnly(), apiKeyHandler.DeleteAPIKey)
 
 		// Design routes
-		protected.GET("/designs", designHandler.GetDesigns)
-		protected.POST("/designs", designHandler.CreateDesign)
-		protected.GET("/designs/:id", designHandler.GetDesign)
-		protected.PUT("/designs/:id", designHandler.UpdateDesign)
-		protected.DELETE("/designs/:id", designHandler.DeleteDesign)
-		protected.POST("/designs/:id/export", designHandler.ExportDesign)
+		protected.GET("/designs", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetDesigns)
+		protected.POST("/designs", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.CreateDesign)
+		protected.GET("/designs/recent", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetRecentDesigns)
+		protected.GET("/search", middleware.RequireScope(models.ScopeDesignsRead), designHandler.SearchDesigns)
+		protected.GET("/designs/:id", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetDesign)
+		protected.PUT("/designs/:id", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.UpdateDesign)
+		protected.DELETE("/designs/:id", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.DeleteDesign)
+		protected.POST("/designs/:id/export", middleware.RequireScope(models.ScopeExport), designHandler.ExportDesign)
+		protected.POST("/designs/:id/save-as-template", middleware.RequireScope(models.ScopeDesignsWrite), templateHandler.SaveDesignAsTemplate)
+		protected.PUT("/designs/:id/owner", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.TransferDesign)
+		protected.PUT("/designs/:id/folder", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.MoveDesign)
+		protected.PUT("/designs/:id/star", middleware.RequireScope(models.ScopeDesignsRead), designHandler.StarDesign)
+		protected.DELETE("/designs/:id/star", middleware.RequireScope(models.ScopeDesignsRead), designHandler.UnstarDesign)
+
+		// Design folder routes; sharing is managed from a signed-in session
+		protected.GET("/design-folders", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetFolders)
+		protected.POST("/design-folders", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.CreateFolder)
+		protected.GET("/design-folders/:id", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetFolder)
+		protected.PUT("/design-folders/:id", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.UpdateFolder)
+		protected.DELETE("/design-folders/:id", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.DeleteFolder)
+		protected.GET("/design-folders/:id/shares", middleware.RequireScope(models.ScopeDesignsRead), designHandler.GetFolderShares)
+		protected.POST("/design-folders/:id/shares", middleware.SessionOnly(), designHandler.ShareFolder)
+		protected.DELETE("/design-folders/:id/shares/:userId", middleware.SessionOnly(), designHandler.UnshareFolder)
+
+		// Template routes; users may only modify their own private templates
+		protected.PUT("/templates/:id", middleware.RequireScope(models.ScopeDesignsWrite), templateHandler.UpdateOwnTemplate)
+		protected.DELETE("/templates/:id", middleware.RequireScope(models.ScopeDesignsWrite), templateHandler.DeleteOwnTemplate)
+		protected.POST("/templates/:id/use", middleware.RequireScope(models.ScopeDesignsWrite), designHandler.UseTemplate)
+		protected.POST("/templates/:id/generate", middleware.RequireScope(models.ScopeDesignsWrite), generationHandler.GenerateDesigns)
+		protected.GET("/generation-jobs/:id", middleware.RequireScope(models.ScopeDesignsRead), generationHandler.GetJob)
+		protected.GET("/generation-jobs/:id/download", middleware.RequireScope(models.ScopeExport), generationHandler.DownloadJob)
 
 		// Upload routes
-		protected.POST("/upload", uploadHandler.UploadImage)
+		protected.POST("/upload", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.UploadImage)
+		protected.POST("/uploads", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.CreateUploadSession)
+		protected.GET("/uploads/:id", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.GetUploadSession)
+		protected.PUT("/uploads/:id/chunks/:index", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.PutUploadChunk)
+		protected.POST("/uploads/:id/complete", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.CompleteUpload)
+		protected.DELETE("/uploads/:id", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.CancelUpload)
+		protected.GET("/assets/:id/url", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetAssetURL)
+		protected.GET("/assets/:id/render", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.RenderAsset)
+		protected.GET("/assets/:id/fabric", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetAssetFabric)
+
+		// Asset library routes
+		protected.GET("/assets", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetAssets)
+		protected.POST("/assets/import", middleware.RequireScope(models.ScopeDesignsWrite), uploadHandler.ImportImage)
+		protected.GET("/assets/:id", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetAsset)
+		protected.GET("/assets/:id/usages", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetAssetUsages)
+		protected.PUT("/assets/:id", middleware.RequireScope(models.ScopeDesignsWrite), assetHandler.UpdateAsset)
+		protected.DELETE("/assets/:id", middleware.RequireScope(models.ScopeDesignsWrite), assetHandler.DeleteAsset)
+		protected.GET("/asset-folders", middleware.RequireScope(models.ScopeDesignsRead), assetHandler.GetFolders)
+		protected.POST("/asset-folders", middleware.RequireScope(models.ScopeDesignsWrite), assetHandler.CreateFolder)
+		protected.PUT("/asset-folders/:id", middleware.RequireScope(models.ScopeDesignsWrite), assetHandler.UpdateFolder)
+		protected.DELETE("/asset-folders/:id", middleware.RequireScope(models.ScopeDesignsWrite), assetHandler.DeleteFolder)
+
+		// Font library routes
+		protected.GET("/fonts", middleware.RequireScope(models.ScopeDesignsRead), fontHandler.GetFonts)
+		protected.POST("/fonts", middleware.RequireScope(models.ScopeDesignsWrite), fontHandler.UploadFont)
+		protected.DELETE("/fonts/:id", middleware.RequireScope(models.ScopeDesignsWrite), fontHandler.DeleteFont)
+
+		// Brand kit routes
+		protected.GET("/brand-kits", middleware.RequireScope(models.ScopeDesignsRead), brandKitHandler.GetBrandKits)
+		protected.POST("/brand-kits", middleware.RequireScope(models.ScopeDesignsWrite), brandKitHandler.CreateBrandKit)
+		protected.GET("/brand-kits/:id", middleware.RequireScope(models.ScopeDesignsRead), brandKitHandler.GetBrandKit)
+		protected.PUT("/brand-kits/:id", middleware.RequireScope(models.ScopeDesignsWrite), brandKitHandler.UpdateBrandKit)
+		protected.DELETE("/brand-kits/:id", middleware.RequireScope(models.ScopeDesignsWrite), brandKitHandler.DeleteBrandKit)
+		protected.POST("/brand-kits/:id/check", middleware.RequireScope(models.ScopeDesignsRead), brandKitHandler.CheckDesign)
+
+		// Team routes; membership is managed from a signed-in session
+		protected.GET("/teams", middleware.RequireScope(models.ScopeDesignsRead), teamHandler.GetTeams)
+		protected.POST("/teams", middleware.SessionOnly(), teamHandler.CreateTeam)
+		protected.GET("/teams/:id", middleware.RequireScope(models.ScopeDesignsRead), teamHandler.GetTeam)
+		protected.PUT("/teams/:id", middleware.SessionOnly(), teamHandler.UpdateTeam)
+		protected.DELETE("/teams/:id", middleware.SessionOnly(), teamHandler.DeleteTeam)
+		protected.PUT("/teams/:id/members/:userId", middleware.SessionOnly(), teamHandler.UpdateTeamMember)
+		protected.DELETE("/teams/:id/members/:userId", middleware.SessionOnly(), teamHandler.RemoveTeamMember)
+		protected.GET("/teams/:id/invitations", middleware.SessionOnly(), teamHandler.GetTeamInvitations)
+		protected.POST("/teams/:id/invitations", middleware.SessionOnly(), teamHandler.InviteTeamMember)
+		protected.DELETE("/teams/:id/invitations/:invitationId", middleware.SessionOnly(), teamHandler.RevokeTeamInvitation)
+		protected.POST("/team-invitations/accept", middleware.SessionOnly(), teamHandler.AcceptTeamInvitation)
+	}
+
+	// Admin routes
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(db), middleware.SessionOnly(), middleware.AdminMiddleware())
+	{
+		admin.GET("/users", adminHandler.GetUsers)
+		admin.GET("/users/:id", adminHandler.GetUser)
+		admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+		admin.POST("/users/:id/suspend", adminHandler.SuspendUser)
+		admin.POST("/users/:id/unsuspend", adminHandler.UnsuspendUser)
+		admin.POST("/users/:id/impersonate", adminHandler.ImpersonateUser)
+		admin.POST("/users/:id/revoke-sessions", adminHandler.RevokeUserSessions)
+		admin.PUT("/users/:id/storage-quota", adminHandler.SetStorageQuota)
+		admin.GET("/stats", adminHandler.GetStats)
+		admin.GET("/audit-logs", adminHandler.GetAuditLogs)
+	}
+
+	// Template admin routes also accept API keys with the templates:admin scope
+	templateAdmin := r.Group("/api/admin/templates")
+	templateAdmin.Use(middleware.AuthMiddleware(db), middleware.AdminMiddleware(), middleware.RequireScope(models.ScopeTemplatesAdmin))
+	{
+		templateAdmin.GET("", templateHandler.AdminGetTemplates)
+		templateAdmin.POST("", templateHandler.CreateTemplate)
+		templateAdmin.PUT("/:id", templateHandler.UpdateTemplate)
+		templateAdmin.DELETE("/:id", templateHandler.DeleteTemplate)
+		templateAdmin.POST("/:id/publish", templateHandler.PublishTemplate)
+		templateAdmin.POST("/:id/unpublish", templateHandler.UnpublishTemplate)
+		templateAdmin.POST("/:id/thumbnail", templateHandler.UploadTemplateThumbnail)
+		templateAdmin.POST("/from-design/:id", templateHandler.PromoteDesign)
 	}
 
 	// Health check
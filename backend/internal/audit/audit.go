@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+)
+
+// Entry is a single row of the audit_logs table. ActorID and TargetID are
+// optional; metadata is stored as JSONB. ImpersonatorID is set when an
+// admin acts as ActorID.
+type Entry struct {
+	ActorID        string
+	Action         string
+	TargetID       string
+	IPAddress      string
+	ImpersonatorID string
+	Metadata       map[string]interface{}
+}
+
+// Record writes an audit entry. Failures are logged rather than returned so
+// that auditing never breaks the request that triggered it.
+func Record(db *sql.DB, entry Entry) {
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		metadata = []byte("{}")
+	}
+
+	query := `INSERT INTO audit_logs (actor_id, action, target_id, ip_address, impersonator_id, metadata) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = db.Exec(query, nullString(entry.ActorID), entry.Action, nullString(entry.TargetID), nullString(entry.IPAddress),
+		nullString(entry.ImpersonatorID), string(metadata))
+	if err != nil {
+		log.Printf("Failed to write audit log %s: %v", entry.Action, err)
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,46 @@
+package fabric
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// assetRefPattern matches the content hashes and ids that asset URLs are
+// built from.
+var assetRefPattern = regexp.MustCompile(`(?i)[0-9a-f]{64}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// AssetRefs returns the asset hashes and ids mentioned anywhere in the
+// canvas, lower-cased, sorted and without duplicates, so designs can be
+// matched to the assets they use.
+func AssetRefs(canvas interface{}) []string {
+	seen := map[string]bool{}
+	collectAssetRefs(canvas, seen, 0)
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func collectAssetRefs(v interface{}, seen map[string]bool, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	switch value := v.(type) {
+	case string:
+		for _, match := range assetRefPattern.FindAllString(value, -1) {
+			seen[strings.ToLower(match)] = true
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			collectAssetRefs(item, seen, depth+1)
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectAssetRefs(item, seen, depth+1)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package fabric
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BrandRules are the colors and font families a brand allows. An empty list
+// allows anything.
+type BrandRules struct {
+	Colors []string
+	Fonts  []string
+}
+
+// BrandIssue is a color or font family used on a canvas that the brand does
+// not allow, with the paths of the objects that use it.
+type BrandIssue struct {
+	Value string   `json:"value"`
+	Paths []string `json:"paths"`
+}
+
+// BrandReport is the result of checking a canvas against BrandRules.
+type BrandReport struct {
+	Valid          bool         `json:"valid"`
+	OffBrandColors []BrandIssue `json:"off_brand_colors"`
+	OffBrandFonts  []BrandIssue `json:"off_brand_fonts"`
+	ObjectsChecked int          `json:"objects_checked"`
+}
+
+// namedColors are the CSS color keywords the editor's color picker offers.
+var namedColors = map[string]string{
+	"black": "#000000", "white": "#ffffff", "red": "#ff0000", "green": "#008000", "blue": "#0000ff",
+	"yellow": "#ffff00", "orange": "#ffa500", "purple": "#800080", "gray": "#808080", "grey": "#808080",
+}
+
+// CheckBrand reports the colors (fills, strokes, backgrounds, gradient
+// stops and per-character styles) and font families used on a canvas that
+// rules do not allow. Colors are compared as #rrggbb, ignoring alpha; font
+// families case-insensitively by their first entry. Template placeholders
+// are skipped.
+func CheckBrand(canvas interface{}, rules BrandRules) *BrandReport {
+	c := brandCheck{
+		colors:    map[string]bool{},
+		fonts:     map[string]bool{},
+		badColors: map[string][]string{},
+		badFonts:  map[string][]string{},
+	}
+	for _, color := range rules.Colors {
+		if normalized, ok := NormalizeColor(color); ok {
+			c.colors[normalized] = true
+		}
+	}
+	for _, font := range rules.Fonts {
+		if family := primaryFamily(font); family != "" {
+			c.fonts[strings.ToLower(family)] = true
+		}
+	}
+
+	if root, ok := canvas.(map[string]interface{}); ok {
+		for _, key := range []string{"background", "backgroundColor"} {
+			c.color(root[key], "canvas_data."+key)
+		}
+		for _, key := range []string{"objects", "elements"} {
+			c.objects(root[key], "canvas_data."+key, 0)
+		}
+	}
+
+	return &BrandReport{
+		Valid:          len(c.badColors) == 0 && len(c.badFonts) == 0,
+		OffBrandColors: issues(c.badColors),
+		OffBrandFonts:  issues(c.badFonts),
+		ObjectsChecked: c.checked,
+	}
+}
+
+type brandCheck struct {
+	colors    map[string]bool
+	fonts     map[string]bool
+	badColors map[string][]string
+	badFonts  map[string][]string
+	checked   int
+}
+
+func (c *brandCheck) objects(value interface{}, path string, depth int) {
+	objects, ok := value.([]interface{})
+	if !ok || depth > maxDepth {
+		return
+	}
+	for i, item := range objects {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		c.checked++
+
+		objectType := strings.ToLower(stringValue(object["type"]))
+		// Fabric serialises a default fill on images that is never drawn
+		if objectType != "image" {
+			for _, key := range colorProperties {
+				c.color(object[key], itemPath+"."+key)
+			}
+		}
+		if isTextType(objectType) {
+			c.color(object["textBackgroundColor"], itemPath+".textBackgroundColor")
+			c.font(object["fontFamily"], itemPath+".fontFamily")
+			c.styles(object["styles"], itemPath+".styles")
+		}
+
+		c.objects(object["objects"], itemPath+".objects", depth+1)
+	}
+}
+
+// styles checks per-character text styles, serialised by Fabric v5 as
+// {line: {char: style}} and by v6 as [{start, end, style}].
+func (c *brandCheck) styles(value interface{}, path string) {
+	check := func(style interface{}, stylePath string) {
+		if properties, ok := style.(map[string]interface{}); ok {
+			for _, key := range []string{"fill", "stroke", "textBackgroundColor"} {
+				c.color(properties[key], stylePath+"."+key)
+			}
+			c.font(properties["fontFamily"], stylePath+".fontFamily")
+		}
+	}
+
+	switch styles := value.(type) {
+	case []interface{}:
+		for i, item := range styles {
+			if span, ok := item.(map[string]interface{}); ok {
+				check(span["style"], fmt.Sprintf("%s[%d].style", path, i))
+			}
+		}
+	case map[string]interface{}:
+		for line, chars := range styles {
+			if chars, ok := chars.(map[string]interface{}); ok {
+				for char, style := range chars {
+					check(style, fmt.Sprintf("%s.%s.%s", path, line, char))
+				}
+			}
+		}
+	}
+}
+
+func (c *brandCheck) color(value interface{}, path string) {
+	if len(c.colors) == 0 {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		v = strings.TrimSpace(v)
+		if v == "" || placeholderPattern.MatchString(v) {
+			return
+		}
+		lower := strings.ToLower(v)
+		if lower == "none" || lower == "transparent" {
+			return
+		}
+		normalized, ok := NormalizeColor(v)
+		if !ok {
+			normalized = lower
+		}
+		if !c.colors[normalized] {
+			c.badColors[normalized] = append(c.badColors[normalized], path)
+		}
+	case map[string]interface{}:
+		// Gradients are judged by their stops
+		if stops, ok := v["colorStops"].([]interface{}); ok {
+			for i, stop := range stops {
+				if stop, ok := stop.(map[string]interface{}); ok {
+					c.color(stop["color"], fmt.Sprintf("%s.colorStops[%d].color", path, i))
+				}
+			}
+		}
+	}
+}
+
+func (c *brandCheck) font(value interface{}, path string) {
+	if len(c.fonts) == 0 {
+		return
+	}
+	family := primaryFamily(stringValue(value))
+	if family == "" || placeholderPattern.MatchString(family) {
+		return
+	}
+	if !c.fonts[strings.ToLower(family)] {
+		c.badFonts[family] = append(c.badFonts[family], path)
+	}
+}
+
+// NormalizeColor converts a hex, rgb()/rgba() or basic named color to
+// lowercase #rrggbb, dropping any alpha.
+func NormalizeColor(value string) (string, bool) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	if named, ok := namedColors[s]; ok {
+		return named, true
+	}
+
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) == 8 {
+			hex = hex[:6]
+		}
+		if len(hex) != 6 {
+			return "", false
+		}
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+			return "", false
+		}
+		return "#" + hex, true
+	}
+
+	for _, prefix := range []string{"rgba(", "rgb("} {
+		if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, ")") {
+			continue
+		}
+		parts := strings.FieldsFunc(s[len(prefix):len(s)-1], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) < 3 {
+			return "", false
+		}
+		channels := make([]int, 3)
+		for i := range channels {
+			n, err := channel(parts[i])
+			if err != nil {
+				return "", false
+			}
+			channels[i] = n
+		}
+		return fmt.Sprintf("#%02x%02x%02x", channels[0], channels[1], channels[2]), true
+	}
+	return "", false
+}
+
+// channel parses an rgb() component given as 0-255 or a percentage.
+func channel(value string) (int, error) {
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 2.55
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	n *= scale
+	if n < 0 {
+		n = 0
+	}
+	if n > 255 {
+		n = 255
+	}
+	return int(n + 0.5), nil
+}
+
+// primaryFamily returns the first family of a CSS font-family list.
+func primaryFamily(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.Trim(strings.TrimSpace(first), `"'`)
+}
+
+func issues(found map[string][]string) []BrandIssue {
+	list := make([]BrandIssue, 0, len(found))
+	for value, paths := range found {
+		sort.Strings(paths)
+		list = append(list, BrandIssue{Value: value, Paths: paths})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Value < list[j].Value })
+	return list
+}
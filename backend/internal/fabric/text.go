@@ -0,0 +1,18 @@
+package fabric
+
+import "strings"
+
+// ExtractText returns the text of every text object in the canvas, one
+// object per line, for search indexing.
+func ExtractText(canvas interface{}) string {
+	var lines []string
+	walkObjects(canvas, func(object map[string]interface{}) {
+		if !isTextType(strings.ToLower(stringValue(object["type"]))) {
+			return
+		}
+		if text := strings.TrimSpace(stringValue(object["text"])); text != "" {
+			lines = append(lines, text)
+		}
+	})
+	return strings.Join(lines, "\n")
+}
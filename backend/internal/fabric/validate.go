@@ -0,0 +1,100 @@
+package fabric
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxDepth = 16
+
+// objectTypes lists the Fabric.js object types the editor can load. Fabric
+// v6 serialises type names capitalised, so comparison is case-insensitive.
+var objectTypes = map[string]bool{
+	"rect":            true,
+	"circle":          true,
+	"ellipse":         true,
+	"triangle":        true,
+	"line":            true,
+	"polyline":        true,
+	"polygon":         true,
+	"path":            true,
+	"text":            true,
+	"i-text":          true,
+	"itext":           true,
+	"textbox":         true,
+	"image":           true,
+	"group":           true,
+	"activeselection": true,
+}
+
+// Validate checks that data has the shape of a serialised Fabric canvas:
+// a JSON object whose "objects" (or legacy "elements") array contains known
+// object types, with positive width/height when present.
+func Validate(data interface{}) error {
+	canvas, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("canvas_data must be a JSON object")
+	}
+
+	for _, key := range []string{"width", "height"} {
+		if value, exists := canvas[key]; exists {
+			n, ok := value.(float64)
+			if !ok || n <= 0 {
+				return fmt.Errorf("canvas_data.%s must be a positive number", key)
+			}
+		}
+	}
+
+	if background, exists := canvas["background"]; exists && background != nil {
+		switch background.(type) {
+		case string, map[string]interface{}:
+		default:
+			return fmt.Errorf("canvas_data.background must be a color or fill object")
+		}
+	}
+
+	for _, key := range []string{"objects", "elements"} {
+		if value, exists := canvas[key]; exists {
+			if err := validateObjects(value, "canvas_data."+key, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateObjects(value interface{}, path string, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%s is nested too deeply", path)
+	}
+
+	objects, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an array", path)
+	}
+
+	for i, item := range objects {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", itemPath)
+		}
+
+		objectType, _ := object["type"].(string)
+		if objectType == "" {
+			return fmt.Errorf("%s.type is required", itemPath)
+		}
+		if !objectTypes[strings.ToLower(objectType)] {
+			return fmt.Errorf("%s.type %q is not a supported object type", itemPath, objectType)
+		}
+
+		if children, exists := object["objects"]; exists {
+			if err := validateObjects(children, itemPath+".objects", depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
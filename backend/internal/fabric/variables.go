@@ -0,0 +1,182 @@
+package fabric
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Variable kinds
+const (
+	VariableText  = "text"
+	VariableImage = "image"
+	VariableColor = "color"
+)
+
+// Variable is a named placeholder declared by a template. Text placeholders
+// are written as {{name}} inside a text object's text; colors as {{name}} in
+// fill, stroke or backgroundColor; image slots either as {{name}} in src or
+// with a "variable" property on an image object.
+type Variable struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+var colorProperties = []string{"fill", "stroke", "backgroundColor"}
+
+// ExtractVariables lists the placeholders used in canvas, sorted by name.
+func ExtractVariables(canvas interface{}) []Variable {
+	found := map[string]string{}
+	add := func(name, kind string) {
+		if _, exists := found[name]; !exists {
+			found[name] = kind
+		}
+	}
+
+	root, _ := canvas.(map[string]interface{})
+	if background, ok := root["background"].(string); ok {
+		for _, name := range placeholderNames(background) {
+			add(name, VariableColor)
+		}
+	}
+
+	walkObjects(canvas, func(object map[string]interface{}) {
+		objectType := strings.ToLower(stringValue(object["type"]))
+		if isTextType(objectType) {
+			for _, name := range placeholderNames(stringValue(object["text"])) {
+				add(name, VariableText)
+			}
+		}
+		if objectType == "image" {
+			if name := stringValue(object["variable"]); name != "" {
+				add(name, VariableImage)
+			}
+			for _, name := range placeholderNames(stringValue(object["src"])) {
+				add(name, VariableImage)
+			}
+		}
+		for _, property := range colorProperties {
+			for _, name := range placeholderNames(stringValue(object[property])) {
+				add(name, VariableColor)
+			}
+		}
+	})
+
+	variables := make([]Variable, 0, len(found))
+	for name, kind := range found {
+		variables = append(variables, Variable{Name: name, Kind: kind})
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables
+}
+
+// ApplyVariables returns a copy of canvas with every placeholder replaced by
+// its value. Placeholders without a value are left untouched.
+func ApplyVariables(canvas interface{}, values map[string]string) interface{} {
+	copied := deepCopy(canvas)
+
+	if root, ok := copied.(map[string]interface{}); ok {
+		if background, ok := root["background"].(string); ok {
+			root["background"] = Substitute(background, values)
+		}
+	}
+
+	walkObjects(copied, func(object map[string]interface{}) {
+		objectType := strings.ToLower(stringValue(object["type"]))
+		if isTextType(objectType) {
+			if text, ok := object["text"].(string); ok {
+				object["text"] = Substitute(text, values)
+			}
+		}
+		if objectType == "image" {
+			if value, ok := values[stringValue(object["variable"])]; ok && value != "" {
+				object["src"] = value
+			} else if src, ok := object["src"].(string); ok {
+				object["src"] = Substitute(src, values)
+			}
+		}
+		for _, property := range colorProperties {
+			if color, ok := object[property].(string); ok {
+				object[property] = Substitute(color, values)
+			}
+		}
+	})
+
+	return copied
+}
+
+// Substitute replaces {{name}} placeholders in s.
+func Substitute(s string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// walkObjects calls fn for every object in the canvas, including objects
+// nested inside groups.
+func walkObjects(canvas interface{}, fn func(map[string]interface{})) {
+	root, ok := canvas.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"objects", "elements"} {
+		walkList(root[key], fn, 0)
+	}
+}
+
+func walkList(value interface{}, fn func(map[string]interface{}), depth int) {
+	objects, ok := value.([]interface{})
+	if !ok || depth > maxDepth {
+		return
+	}
+	for _, item := range objects {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fn(object)
+		walkList(object["objects"], fn, depth+1)
+	}
+}
+
+func placeholderNames(s string) []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+func isTextType(objectType string) bool {
+	return objectType == "text" || objectType == "i-text" || objectType == "itext" || objectType == "textbox"
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = deepCopy(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = deepCopy(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
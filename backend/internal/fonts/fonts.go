@@ -0,0 +1,234 @@
+// Package fonts reads the metadata of uploaded font files.
+package fonts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"mime"
+	"strings"
+	"unicode/utf16"
+)
+
+type Format string
+
+const (
+	TrueType Format = "truetype"
+	OpenType Format = "opentype"
+	WOFF2    Format = "woff2"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported font format; use TTF, OTF or WOFF2")
+	ErrInvalidFont       = errors.New("invalid or corrupt font file")
+)
+
+func init() {
+	// Not in Go's built-in table; storage derives content types from it
+	mime.AddExtensionType(".ttf", "font/ttf")
+	mime.AddExtensionType(".otf", "font/otf")
+	mime.AddExtensionType(".woff2", "font/woff2")
+}
+
+func (f Format) MimeType() string {
+	switch f {
+	case TrueType:
+		return "font/ttf"
+	case OpenType:
+		return "font/otf"
+	}
+	return "font/woff2"
+}
+
+func (f Format) Ext() string {
+	switch f {
+	case TrueType:
+		return ".ttf"
+	case OpenType:
+		return ".otf"
+	}
+	return ".woff2"
+}
+
+// Info is the metadata of a font face.
+type Info struct {
+	Format    Format
+	Family    string
+	Subfamily string
+	FullName  string
+	// Weight is the CSS weight, 100-900
+	Weight int
+	// Style is "normal" or "italic"
+	Style string
+}
+
+// Sniff identifies a font format from its magic bytes.
+func Sniff(data []byte) (Format, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	switch string(data[:4]) {
+	case "\x00\x01\x00\x00", "true":
+		return TrueType, true
+	case "OTTO":
+		return OpenType, true
+	case "wOF2":
+		return WOFF2, true
+	}
+	return "", false
+}
+
+// Parse reads a font's family, weight and style from its name and OS/2
+// tables.
+func Parse(data []byte) (*Info, error) {
+	format, ok := Sniff(data)
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	var tables map[string][]byte
+	var err error
+	if format == WOFF2 {
+		tables, err = woff2Tables(data, "name", "OS/2")
+	} else {
+		tables, err = sfntTables(data, "name", "OS/2")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Format: format, Weight: 400, Style: "normal"}
+	names := parseNames(tables["name"])
+	info.Family = firstOf(names[16], names[1])
+	info.Subfamily = firstOf(names[17], names[2])
+	info.FullName = firstOf(names[4], strings.TrimSpace(info.Family+" "+info.Subfamily))
+	if info.Family == "" {
+		return nil, ErrInvalidFont
+	}
+
+	if os2 := tables["OS/2"]; len(os2) >= 64 {
+		if weight := int(binary.BigEndian.Uint16(os2[4:])); weight >= 1 && weight <= 1000 {
+			info.Weight = weight
+		}
+		// fsSelection bit 0 is italic, bit 9 oblique
+		if selection := binary.BigEndian.Uint16(os2[62:]); selection&0x0201 != 0 {
+			info.Style = "italic"
+		}
+	} else {
+		subfamily := strings.ToLower(info.Subfamily)
+		if strings.Contains(subfamily, "bold") {
+			info.Weight = 700
+		}
+		if strings.Contains(subfamily, "italic") || strings.Contains(subfamily, "oblique") {
+			info.Style = "italic"
+		}
+	}
+	return info, nil
+}
+
+// sfntTables returns the requested tables of a TTF/OTF file.
+func sfntTables(data []byte, tags ...string) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidFont
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, ErrInvalidFont
+	}
+
+	tables := map[string][]byte{}
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		tag := string(record[:4])
+		if !contains(tags, tag) {
+			continue
+		}
+		offset := int64(binary.BigEndian.Uint32(record[8:]))
+		length := int64(binary.BigEndian.Uint32(record[12:]))
+		if offset+length > int64(len(data)) {
+			return nil, ErrInvalidFont
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// parseNames reads the name table, preferring English Windows (UTF-16)
+// records, then Unicode, then Mac Roman.
+func parseNames(table []byte) map[uint16]string {
+	names := map[uint16]string{}
+	if len(table) < 6 {
+		return names
+	}
+	count := int(binary.BigEndian.Uint16(table[2:]))
+	storage := int(binary.BigEndian.Uint16(table[4:]))
+
+	rank := map[uint16]int{}
+	for i := 0; i < count; i++ {
+		start := 6 + 12*i
+		if start+12 > len(table) {
+			break
+		}
+		record := table[start:]
+		platform := binary.BigEndian.Uint16(record[0:])
+		language := binary.BigEndian.Uint16(record[4:])
+		nameID := binary.BigEndian.Uint16(record[6:])
+		length := int(binary.BigEndian.Uint16(record[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+		if offset+length > len(table) {
+			continue
+		}
+		raw := table[offset : offset+length]
+
+		var value string
+		var score int
+		switch platform {
+		case 3:
+			value, score = decodeUTF16(raw), 2
+			if language == 0x0409 {
+				score = 3
+			}
+		case 0:
+			value, score = decodeUTF16(raw), 1
+		case 1:
+			value, score = string(bytes.ToValidUTF8(raw, nil)), 0
+		default:
+			continue
+		}
+		value = strings.TrimSpace(strings.ToValidUTF8(value, ""))
+		if value == "" {
+			continue
+		}
+		if current, seen := rank[nameID]; !seen || score > current {
+			names[nameID] = value
+			rank[nameID] = score
+		}
+	}
+	return names
+}
+
+func decodeUTF16(raw []byte) string {
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(raw[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
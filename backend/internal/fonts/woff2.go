@@ -0,0 +1,121 @@
+package fonts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxSfntSize bounds the decompressed size of a WOFF2 font.
+const maxSfntSize = 64 * 1024 * 1024
+
+// woff2KnownTags is the WOFF2 table of tags that can be stored as a 6-bit
+// index instead of spelled out.
+var woff2KnownTags = [63]string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post", "cvt ", "fpgm", "glyf", "loca", "prep",
+	"CFF ", "VORG", "EBDT", "EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea", "vmtx", "BASE",
+	"GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH", "CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt",
+	"avar", "bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar", "gvar", "hsty", "just", "lcar",
+	"mort", "morx", "opbd", "prop", "trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+// woff2Tables returns the requested tables of a WOFF2 font. All tables
+// share one Brotli stream in directory order; name and OS/2 are never
+// transformed, so they can be read as-is.
+func woff2Tables(data []byte, tags ...string) (map[string][]byte, error) {
+	if len(data) < 48 {
+		return nil, ErrInvalidFont
+	}
+	if string(data[4:8]) == "ttcf" {
+		// Font collections hold several faces; upload them individually
+		return nil, ErrUnsupportedFormat
+	}
+	numTables := int(binary.BigEndian.Uint16(data[12:]))
+	compressedSize := int64(binary.BigEndian.Uint32(data[20:]))
+
+	type entry struct {
+		tag    string
+		length int64
+	}
+	r := bytes.NewReader(data[48:])
+	entries := make([]entry, 0, numTables)
+	for i := 0; i < numTables; i++ {
+		flags, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidFont
+		}
+
+		var tag string
+		if index := flags & 0x3f; index == 63 {
+			var raw [4]byte
+			if _, err := io.ReadFull(r, raw[:]); err != nil {
+				return nil, ErrInvalidFont
+			}
+			tag = string(raw[:])
+		} else {
+			tag = woff2KnownTags[index]
+		}
+
+		length, err := readUIntBase128(r)
+		if err != nil {
+			return nil, err
+		}
+		// glyf and loca are transformed unless version 3; others only when non-zero
+		transform := flags >> 6
+		transformed := transform != 0
+		if tag == "glyf" || tag == "loca" {
+			transformed = transform != 3
+		}
+		if transformed {
+			if length, err = readUIntBase128(r); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry{tag: tag, length: length})
+	}
+
+	start := int64(len(data) - r.Len())
+	if start+compressedSize > int64(len(data)) {
+		return nil, ErrInvalidFont
+	}
+	stream := brotli.NewReader(bytes.NewReader(data[start : start+compressedSize]))
+	sfnt, err := io.ReadAll(io.LimitReader(stream, maxSfntSize))
+	if err != nil {
+		return nil, ErrInvalidFont
+	}
+
+	tables := map[string][]byte{}
+	var offset int64
+	for _, e := range entries {
+		if offset+e.length > int64(len(sfnt)) {
+			return nil, ErrInvalidFont
+		}
+		if contains(tags, e.tag) {
+			tables[e.tag] = sfnt[offset : offset+e.length]
+		}
+		offset += e.length
+	}
+	return tables, nil
+}
+
+// readUIntBase128 reads WOFF2's variable-length unsigned integer.
+func readUIntBase128(r io.ByteReader) (int64, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, ErrInvalidFont
+		}
+		// No leading zeros, and the value must fit in 32 bits
+		if (i == 0 && b == 0x80) || value&0xFE000000 != 0 {
+			return 0, ErrInvalidFont
+		}
+		value = value<<7 | uint32(b&0x7f)
+		if b&0x80 == 0 {
+			return int64(value), nil
+		}
+	}
+	return 0, ErrInvalidFont
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/utils"
+)
+
+const (
+	accountDeletionGracePeriod = 30 * 24 * time.Hour
+	emailVerificationTTL       = 24 * time.Hour
+)
+
+func (h *SimpleAuthHandler) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.checkPassword(userID.(string), req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	// Signing out every other session and revoking API keys cuts off anyone
+	// who got hold of the old password
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET password = $1, sessions_revoked_at = NOW(), updated_at = NOW() WHERE id = $2`
+	if _, err := tx.Exec(query, string(hashedPassword), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "account.password_changed", TargetID: userID.(string), IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID")})
+
+	// The caller's own token was revoked with the rest
+	token, err := utils.GenerateJWT(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully", "token": token})
+}
+
+func (h *SimpleAuthHandler) ChangeEmail(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.checkPassword(userID.(string), req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	var taken bool
+	if err := h.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`, req.Email).Scan(&taken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	}
+
+	token, err := utils.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
+	}
+
+	// A new request supersedes any pending one
+	h.db.Exec(`DELETE FROM email_verifications WHERE user_id = $1`, userID)
+	query := `INSERT INTO email_verifications (user_id, new_email, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := h.db.Exec(query, userID, req.Email, utils.HashToken(token), time.Now().Add(emailVerificationTTL)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(h.appURL, "/"), token)
+	body := fmt.Sprintf("Confirm your new Canvas Designer email address by opening this link within 24 hours:\n\n%s\n", link)
+	if err := h.mailer.Send(req.Email, "Confirm your new email address", body); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent to the new address"})
+}
+
+func (h *SimpleAuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `DELETE FROM email_verifications WHERE token_hash = $1 AND expires_at > NOW() RETURNING user_id, new_email`
+	var userID, newEmail string
+	if err := h.db.QueryRow(query, utils.HashToken(req.Token)).Scan(&userID, &newEmail); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	query = `UPDATE users SET email = $1, updated_at = NOW() WHERE id = $2 RETURNING id, email, name, created_at, updated_at`
+	var user models.User
+	err := h.db.QueryRow(query, newEmail, userID).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID, Action: "account.email_changed", TargetID: userID, IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID")})
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *SimpleAuthHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.checkPassword(userID.(string), req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	// Team content outlives its creator, but a team must keep an owner
+	var lastOwner bool
+	query := `SELECT EXISTS(SELECT 1 FROM team_members m WHERE m.user_id = $1 AND m.role = 'owner'
+		AND NOT EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = m.team_id AND o.role = 'owner' AND o.user_id <> m.user_id))`
+	if err := h.db.QueryRow(query, userID).Scan(&lastOwner); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+	if lastOwner {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are the last owner of a team; transfer ownership or delete the team first"})
+		return
+	}
+
+	scheduledAt := time.Now().Add(accountDeletionGracePeriod)
+	query = `UPDATE users SET deletion_scheduled_at = $1 WHERE id = $2`
+	if _, err := h.db.Exec(query, scheduledAt, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "account.deletion_scheduled", TargetID: userID.(string), IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID")})
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+func (h *SimpleAuthHandler) CancelAccountDeletion(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := `UPDATE users SET deletion_scheduled_at = NULL WHERE id = $1 AND deletion_scheduled_at IS NOT NULL`
+	result, err := h.db.Exec(query, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending account deletion"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "account.deletion_cancelled", TargetID: userID.(string), IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID")})
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// ExportAccount streams a zip archive with the user's profile, designs and
+// the files they uploaded: their assets with every stored variant, and
+// their fonts. Files the scanner rejected are left out.
+func (h *SimpleAuthHandler) ExportAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := `SELECT id, email, name, created_at, updated_at, deletion_scheduled_at FROM users WHERE id = $1`
+	var user models.User
+	err := h.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt, &user.DeletionScheduledAt)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	query = `SELECT id, title, COALESCE(description, ''), COALESCE(canvas_data::text, ''), COALESCE(thumbnail, ''), created_at, updated_at FROM designs WHERE user_id = $1 ORDER BY created_at`
+	rows, err := h.db.Query(query, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch designs"})
+		return
+	}
+	defer rows.Close()
+
+	var designs []models.Design
+	for rows.Next() {
+		var design models.Design
+		var canvasData string
+		if err := rows.Scan(&design.ID, &design.Title, &design.Description, &canvasData, &design.Thumbnail, &design.CreatedAt, &design.UpdatedAt); err != nil {
+			continue
+		}
+		if canvasData != "" {
+			json.Unmarshal([]byte(canvasData), &design.CanvasData)
+		}
+		design.UserID = user.ID
+		designs = append(designs, design)
+	}
+
+	query = `SELECT storage_key FROM assets WHERE owner_id = $1 AND scan_status <> 'rejected'
+		UNION SELECT v.storage_key FROM asset_variants v JOIN assets a ON a.id = v.asset_id WHERE a.owner_id = $1
+		UNION SELECT storage_key FROM fonts WHERE owner_id = $1
+		ORDER BY 1`
+	fileRows, err := h.db.Query(query, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch uploads"})
+		return
+	}
+	defer fileRows.Close()
+
+	var keys []string
+	for fileRows.Next() {
+		var key string
+		if err := fileRows.Scan(&key); err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="canvas-designer-export-%s.zip"`, time.Now().Format("20060102")))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+
+	if err := writeZipJSON(zw, "profile.json", user); err != nil {
+		log.Printf("Failed to write account export: %v", err)
+		return
+	}
+	for _, design := range designs {
+		if err := writeZipJSON(zw, fmt.Sprintf("designs/%s.json", design.ID), design); err != nil {
+			log.Printf("Failed to write account export: %v", err)
+			return
+		}
+	}
+	// Files keep their storage keys as paths, e.g. uploads/<hash>-thumb.webp
+	for _, key := range keys {
+		if err := h.writeZipObject(c.Request.Context(), zw, key, key); err != nil {
+			log.Printf("Skipping upload %s in account export: %v", key, err)
+		}
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: user.ID, Action: "account.exported", TargetID: user.ID, IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID")})
+}
+
+func (h *SimpleAuthHandler) checkPassword(userID, password string) bool {
+	var hashedPassword string
+	if err := h.db.QueryRow(`SELECT password FROM users WHERE id = $1`, userID).Scan(&hashedPassword); err != nil {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) == nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeZipObject copies a stored object into the archive under name.
+func (h *SimpleAuthHandler) writeZipObject(ctx context.Context, zw *zip.Writer, name, key string) error {
+	obj, err := h.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, obj.Body)
+	return err
+}
+
+func writeZipBytes(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
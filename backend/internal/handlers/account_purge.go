@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+)
+
+// PurgeDeletedAccounts deletes the accounts whose deletion grace period has
+// elapsed. Personal designs and other owned rows cascade; team content is
+// first handed over to another member of the team.
+func (h *SimpleAuthHandler) PurgeDeletedAccounts() {
+	rows, err := h.db.Query(`SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= NOW()`)
+	if err != nil {
+		log.Printf("Failed to purge deleted accounts: %v", err)
+		return
+	}
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	rows.Close()
+
+	purged := 0
+	for _, userID := range userIDs {
+		if err := h.purgeAccount(context.Background(), userID); err != nil {
+			log.Printf("Failed to purge account %s: %v", userID, err)
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d deleted accounts", purged)
+	}
+}
+
+// teamHandover moves the team content created by $2 in team $1 to the
+// successor $3. Rows that would duplicate one of the successor's own are
+// dropped first, as when a team is deleted. Asset folders are personal, so
+// handed-over assets leave them.
+var teamHandover = []string{
+	`UPDATE team_members SET role = 'owner' WHERE team_id = $1 AND user_id = $3
+		AND NOT EXISTS (SELECT 1 FROM team_members WHERE team_id = $1 AND role = 'owner' AND user_id <> $2)`,
+	`DELETE FROM assets a WHERE a.team_id = $1 AND a.owner_id = $2 AND EXISTS (SELECT 1 FROM assets s
+		WHERE s.team_id = $1 AND s.owner_id = $3 AND s.hash = a.hash)`,
+	`DELETE FROM fonts f WHERE f.team_id = $1 AND f.owner_id = $2 AND EXISTS (SELECT 1 FROM fonts s
+		WHERE s.team_id = $1 AND s.owner_id = $3
+		AND (s.hash = f.hash OR (s.family = f.family AND s.weight = f.weight AND s.style = f.style)))`,
+	`UPDATE assets SET owner_id = $3, folder_id = NULL WHERE team_id = $1 AND owner_id = $2`,
+	`UPDATE fonts SET owner_id = $3 WHERE team_id = $1 AND owner_id = $2`,
+	`UPDATE designs SET user_id = $3 WHERE team_id = $1 AND user_id = $2`,
+	`UPDATE design_folders SET owner_id = $3 WHERE team_id = $1 AND owner_id = $2`,
+	`UPDATE templates SET owner_id = $3 WHERE team_id = $1 AND owner_id = $2`,
+	`UPDATE brand_kits SET owner_id = $3 WHERE team_id = $1 AND owner_id = $2`,
+}
+
+// purgeAccount deletes one account. Each of its teams passes to the
+// longest-standing remaining owner, else admin, else member, who becomes
+// an owner if the team would otherwise have none; teams with no other
+// members are deleted.
+//
+// Stored files are deleted only once the rows are gone, so a failed purge
+// never leaves rows pointing at missing objects. Files of handed-over
+// assets and fonts are still referenced and stay.
+func (h *SimpleAuthHandler) purgeAccount(ctx context.Context, userID string) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT m.team_id, (SELECT o.user_id FROM team_members o WHERE o.team_id = m.team_id AND o.user_id <> m.user_id
+			ORDER BY CASE o.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, o.joined_at LIMIT 1)
+		FROM team_members m WHERE m.user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	successors := map[string]*string{}
+	for rows.Next() {
+		var teamID string
+		var successorID *string
+		if err := rows.Scan(&teamID, &successorID); err != nil {
+			rows.Close()
+			return err
+		}
+		successors[teamID] = successorID
+	}
+	rows.Close()
+
+	// Collected before the teams without a successor are deleted, as their
+	// assets and fonts cascade with them
+	shared, err := purgedObjectKeys(tx, userID)
+	if err != nil {
+		return err
+	}
+	owned, err := purgedUploadKeys(tx, userID)
+	if err != nil {
+		return err
+	}
+	hashes, err := queryKeys(tx, `SELECT DISTINCT hash FROM assets WHERE owner_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for teamID, successorID := range successors {
+		if successorID == nil {
+			if _, err := tx.Exec(`DELETE FROM teams WHERE id = $1`, teamID); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, statement := range teamHandover {
+			if _, err := tx.Exec(statement, teamID, userID, *successorID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Job archives and upload chunks belong to the user alone
+	for _, key := range owned {
+		if err := h.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+	deleteUnreferenced(ctx, h.db, h.store, shared)
+	deleteRenders(ctx, h.db, h.store, hashes)
+	return nil
+}
+
+// purgedObjectKeys lists the storage keys of the user's assets, their
+// variants and the user's fonts, including those of teams they belong to.
+func purgedObjectKeys(tx *sql.Tx, userID string) ([]string, error) {
+	query := `SELECT storage_key FROM assets WHERE owner_id = $1
+		UNION SELECT v.storage_key FROM asset_variants v JOIN assets a ON a.id = v.asset_id WHERE a.owner_id = $1
+		UNION SELECT storage_key FROM fonts WHERE owner_id = $1`
+	return queryKeys(tx, query, userID)
+}
+
+// purgedUploadKeys lists the generation job archives and resumable upload
+// chunks of the user.
+func purgedUploadKeys(tx *sql.Tx, userID string) ([]string, error) {
+	jobIDs, err := queryKeys(tx, `SELECT id FROM generation_jobs WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, jobID := range jobIDs {
+		keys = append(keys, jobArchiveKey(jobID))
+	}
+
+	rows, err := tx.Query(`SELECT id, size, chunk_size FROM upload_sessions WHERE owner_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sessionID string
+		var size int64
+		var chunkSize int
+		if err := rows.Scan(&sessionID, &size, &chunkSize); err != nil {
+			return nil, err
+		}
+		for index := 0; int64(index)*int64(chunkSize) < size; index++ {
+			keys = append(keys, chunkKey(sessionID, index))
+		}
+	}
+	return keys, rows.Err()
+}
+
+func queryKeys(tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
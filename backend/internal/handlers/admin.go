@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/storage"
+	"canvas-designer-backend/internal/utils"
+)
+
+type AdminHandler struct {
+	db    *sql.DB
+	store storage.Storage
+}
+
+func NewAdminHandler(db *sql.DB, store storage.Storage) *AdminHandler {
+	return &AdminHandler{db: db, store: store}
+}
+
+func (h *AdminHandler) GetUsers(c *gin.Context) {
+	limit, offset := parseLimitOffset(c, 50, 200)
+
+	where := "WHERE 1=1"
+	var args []interface{}
+	if q := c.Query("q"); q != "" {
+		args = append(args, containsPattern(q))
+		where += fmt.Sprintf(` AND (u.email ILIKE $%d ESCAPE '\' OR u.name ILIKE $%d ESCAPE '\')`, len(args), len(args))
+	}
+	switch c.Query("status") {
+	case "active":
+		where += " AND u.suspended_at IS NULL"
+	case "suspended":
+		where += " AND u.suspended_at IS NOT NULL"
+	}
+	if role := c.Query("role"); role != "" {
+		args = append(args, role)
+		where += fmt.Sprintf(" AND u.role = $%d", len(args))
+	}
+
+	var total int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM users u `+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`SELECT u.id, u.email, u.name, u.role, u.created_at, u.updated_at, u.suspended_at, u.deletion_scheduled_at,
+		(SELECT COUNT(*) FROM designs d WHERE d.user_id = u.id)
+		FROM users u %s ORDER BY u.created_at DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	defer rows.Close()
+
+	users := []models.AdminUser{}
+	for rows.Next() {
+		var user models.AdminUser
+		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+			&user.SuspendedAt, &user.DeletionScheduledAt, &user.DesignCount)
+		if err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total})
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.findUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	var req models.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if targetID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	result, err := h.db.Exec(`UPDATE users SET role = $1 WHERE id = $2`, req.Role, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{
+		ActorID: adminID, Action: "admin.role_changed", TargetID: targetID, IPAddress: c.ClientIP(),
+		Metadata: map[string]interface{}{"role": req.Role},
+	})
+	h.respondWithUser(c, targetID)
+}
+
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	var req models.SuspendUserRequest
+	c.ShouldBindJSON(&req)
+
+	if targetID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot suspend yourself"})
+		return
+	}
+
+	result, err := h.db.Exec(`UPDATE users SET suspended_at = NOW(), sessions_revoked_at = NOW() WHERE id = $1 AND suspended_at IS NULL`, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found or already suspended"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{
+		ActorID: adminID, Action: "admin.user_suspended", TargetID: targetID, IPAddress: c.ClientIP(),
+		Metadata: map[string]interface{}{"reason": req.Reason},
+	})
+	h.respondWithUser(c, targetID)
+}
+
+func (h *AdminHandler) UnsuspendUser(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	result, err := h.db.Exec(`UPDATE users SET suspended_at = NULL WHERE id = $1 AND suspended_at IS NOT NULL`, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsuspend user"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found or not suspended"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: adminID, Action: "admin.user_unsuspended", TargetID: targetID, IPAddress: c.ClientIP()})
+	h.respondWithUser(c, targetID)
+}
+
+// SetStorageQuota overrides a user's storage quota; a null quota_bytes
+// restores the default.
+func (h *AdminHandler) SetStorageQuota(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	var req models.UpdateStorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.db.Exec(`UPDATE users SET storage_quota_bytes = $1 WHERE id = $2`, req.QuotaBytes, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update storage quota"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{
+		ActorID: adminID, Action: "admin.storage_quota_changed", TargetID: targetID, IPAddress: c.ClientIP(),
+		Metadata: map[string]interface{}{"quota_bytes": req.QuotaBytes},
+	})
+	h.respondWithUser(c, targetID)
+}
+
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	user, err := h.findUser(targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot impersonate a suspended user"})
+		return
+	}
+
+	token, err := utils.GenerateImpersonationJWT(user.ID, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: adminID, Action: "admin.impersonation_started", TargetID: user.ID, IPAddress: c.ClientIP()})
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
+
+func (h *AdminHandler) RevokeUserSessions(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetID := c.Param("id")
+
+	result, err := h.db.Exec(`UPDATE users SET sessions_revoked_at = NOW() WHERE id = $1`, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: adminID, Action: "admin.sessions_revoked", TargetID: targetID, IPAddress: c.ClientIP()})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+}
+
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	var stats models.SystemStats
+	query := `SELECT
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM users WHERE suspended_at IS NULL),
+		(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL),
+		(SELECT COUNT(*) FROM designs),
+		(SELECT COUNT(*) FROM templates)`
+	err := h.db.QueryRow(query).Scan(&stats.Users, &stats.ActiveUsers, &stats.SuspendedUsers, &stats.Designs, &stats.Templates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
+		return
+	}
+
+	if usage, ok := h.store.(storage.UsageReporter); ok {
+		stats.StorageFiles, stats.StorageBytes, _ = usage.Usage(c.Request.Context(), uploadsPrefix)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+func (h *AdminHandler) GetAuditLogs(c *gin.Context) {
+	limit, offset := parseLimitOffset(c, 100, 500)
+
+	where := "WHERE 1=1"
+	var args []interface{}
+	for _, filter := range []string{"action", "actor_id", "target_id"} {
+		if value := c.Query(filter); value != "" {
+			args = append(args, value)
+			where += fmt.Sprintf(" AND %s::text = $%d", filter, len(args))
+		}
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`SELECT id, actor_id, action, target_id, ip_address, impersonator_id, COALESCE(metadata::text, 'null'), created_at
+		FROM audit_logs %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args))
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		var entry models.AuditLog
+		var metadata string
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetID, &entry.IPAddress, &entry.ImpersonatorID, &metadata, &entry.CreatedAt); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(metadata), &entry.Metadata)
+		logs = append(logs, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit_logs": logs})
+}
+
+func (h *AdminHandler) findUser(id string) (*models.AdminUser, error) {
+	query := `SELECT u.id, u.email, u.name, u.role, u.created_at, u.updated_at, u.suspended_at, u.deletion_scheduled_at,
+		(SELECT COUNT(*) FROM designs d WHERE d.user_id = u.id)
+		FROM users u WHERE u.id = $1`
+	var user models.AdminUser
+	err := h.db.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.SuspendedAt, &user.DeletionScheduledAt, &user.DesignCount)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (h *AdminHandler) respondWithUser(c *gin.Context, id string) {
+	user, err := h.findUser(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/storage"
+	"canvas-designer-backend/internal/svg"
+)
+
+const (
+	defaultSignedURLTTL = time.Hour
+	maxSignedURLTTL     = 7 * 24 * time.Hour
+	// Objects that cannot seek (e.g. S3 responses) are buffered up to this
+	// size so Range requests can still be answered.
+	maxBufferedObject = 32 * 1024 * 1024
+)
+
+var hashedKeyPattern = regexp.MustCompile(`^uploads/[0-9a-f]{64}(-[a-z]+)?\.[a-z0-9]+$`)
+
+type AssetHandler struct {
+	db           *sql.DB
+	store        storage.Storage
+	signer       *storage.URLSigner
+	defaultQuota int64
+}
+
+func NewAssetHandler(db *sql.DB, store storage.Storage, signer *storage.URLSigner, defaultQuota int64) *AssetHandler {
+	return &AssetHandler{db: db, store: store, signer: signer, defaultQuota: defaultQuota}
+}
+
+// ServeUpload serves /uploads/<name>. Fonts and files recorded only as
+// private assets require the owner's credentials or a signed URL; files
+// with no record, such as template thumbnails, are public.
+func (h *AssetHandler) ServeUpload(c *gin.Context) {
+	key := uploadsPrefix + strings.TrimPrefix(c.Param("path"), "/")
+
+	signed := h.signer.Verify(key, c.Query("expires"), c.Query("signature"))
+	public, owned, err := h.uploadAccess(key, c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		return
+	}
+	if !public && !owned && !signed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	obj, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+		return
+	}
+	defer obj.Body.Close()
+
+	content, ok := obj.Body.(io.ReadSeeker)
+	if !ok {
+		if obj.Size > maxBufferedObject {
+			c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, obj.Body, nil)
+			return
+		}
+		data, err := io.ReadAll(obj.Body)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch file"})
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	if strings.HasPrefix(contentType, svg.MimeType) {
+		// Opened directly, an SVG is a document; never let it run anything
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox")
+	}
+
+	// Content-hashed files never change, so they can be cached for good
+	cacheScope := "public"
+	if !public {
+		cacheScope = "private"
+	}
+	if hashedKeyPattern.MatchString(key) {
+		c.Header("ETag", `"`+strings.TrimPrefix(key, uploadsPrefix)+`"`)
+		c.Header("Cache-Control", cacheScope+", max-age=31536000, immutable")
+	} else {
+		c.Header("ETag", fmt.Sprintf(`"%x-%x"`, obj.ModTime.Unix(), obj.Size))
+		c.Header("Cache-Control", cacheScope+", max-age=3600")
+	}
+
+	// ServeContent handles Range, If-None-Match and If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, path.Base(key), obj.ModTime, content)
+}
+
+// GetAssetURL returns an expiring URL for one of the user's assets that can
+// be used without credentials, e.g. in <img> tags.
+func (h *AssetHandler) GetAssetURL(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// ?variant= and ?format= select one of the renditions stored on upload
+	var key string
+	query := `SELECT storage_key FROM assets WHERE id = $1 AND ` + workspaceAccess("owner_id", "team_id", "$2") + ` AND scan_status = 'clean'`
+	args := []interface{}{c.Param("id"), userID}
+	if variant := c.Query("variant"); variant != "" {
+		query = `SELECT v.storage_key FROM asset_variants v JOIN assets a ON a.id = v.asset_id
+			WHERE a.id = $1 AND ` + workspaceAccess("a.owner_id", "a.team_id", "$2") + ` AND a.scan_status = 'clean' AND v.name = $3
+			AND (v.format = COALESCE(NULLIF($4, ''), SPLIT_PART(SPLIT_PART(a.mime_type, '/', 2), '+', 1)) OR (v.name = 'original' AND $4 = ''))`
+		args = append(args, variant, c.Query("format"))
+	}
+	if err := h.db.QueryRow(query, args...).Scan(&key); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	ttl := defaultSignedURLTTL
+	if value := c.Query("ttl"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a positive number of seconds"})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+		if ttl > maxSignedURLTTL {
+			ttl = maxSignedURLTTL
+		}
+	}
+
+	url, err := h.store.SignedURL(c.Request.Context(), key, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"expires_at": time.Now().Add(ttl),
+	})
+}
+
+// GetAssetFabric converts one of the user's SVG assets into Fabric objects
+// that can be added to a canvas and edited. Only simple SVGs convert.
+func (h *AssetHandler) GetAssetFabric(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var key, mimeType string
+	query := `SELECT storage_key, mime_type FROM assets WHERE id = $1 AND ` + workspaceAccess("owner_id", "team_id", "$2") + ` AND scan_status = 'clean'`
+	if err := h.db.QueryRow(query, c.Param("id"), userID).Scan(&key, &mimeType); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	if mimeType != svg.MimeType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only SVG assets can be converted"})
+		return
+	}
+
+	obj, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(obj.Body, svg.MaxBytes))
+	obj.Body.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset"})
+		return
+	}
+
+	conversion, err := svg.ToFabric(data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversion)
+}
+
+// uploadAccess reports whether key is public (shared by some owner, or
+// neither an asset nor a font) and whether userID can access it, in their
+// own space or one of their teams. Fonts are never public.
+func (h *AssetHandler) uploadAccess(key, userID string) (public, owned bool, err error) {
+	query := `SELECT COALESCE(BOOL_OR(public), COUNT(*) = 0), COALESCE(BOOL_OR(` + workspaceAccess("owner_id", "team_id", "NULLIF($2, '')::uuid") + `), FALSE) FROM (
+			SELECT visibility = 'public' AS public, owner_id, team_id FROM assets
+			WHERE storage_key = $1 OR id IN (SELECT asset_id FROM asset_variants WHERE storage_key = $1)
+			UNION ALL
+			SELECT FALSE, owner_id, team_id FROM fonts WHERE storage_key = $1
+		) AS owners`
+	err = h.db.QueryRow(query, key, userID).Scan(&public, &owned)
+	return public, owned, err
+}
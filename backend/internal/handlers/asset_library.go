@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/models"
+)
+
+// assetUsageCount counts the designs in the asset's workspace (the owner's
+// personal space or its team) whose canvas references it, either by its id
+// or by its content hash (which appears in its URLs). It is an index lookup
+// on designs.asset_refs rather than a scan of every canvas.
+const assetUsageCount = `(SELECT COUNT(*) FROM designs d
+	WHERE d.asset_refs && ARRAY[assets.hash::text, assets.id::text]
+	AND d.team_id IS NOT DISTINCT FROM assets.team_id AND (assets.team_id IS NOT NULL OR d.user_id = assets.owner_id))`
+
+// GetAssets lists the user's asset library, newest first. It can be
+// filtered by workspace, name (q), folder_id ("root" for assets outside any
+// folder) and tags (all must match), and is paged with next_cursor.
+func (h *AssetHandler) GetAssets(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := parseLimit(c, 50, 100)
+	args := []interface{}{userID}
+	scope, ok := workspaceScope(c, "owner_id", "team_id", "$1", &args)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+		return
+	}
+	where := "WHERE " + scope
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		args = append(args, containsPattern(q))
+		where += fmt.Sprintf(` AND original_name ILIKE $%d ESCAPE '\'`, len(args))
+	}
+	switch folderID := c.Query("folder_id"); folderID {
+	case "":
+	case "root":
+		where += " AND folder_id IS NULL"
+	default:
+		args = append(args, folderID)
+		where += fmt.Sprintf(" AND folder_id = $%d", len(args))
+	}
+	if tags := splitList(c.Query("tags")); len(tags) > 0 {
+		args = append(args, pq.Array(tags), len(tags))
+		where += fmt.Sprintf(` AND id IN (SELECT at.asset_id FROM asset_tags at JOIN tags t ON t.id = at.tag_id
+			WHERE t.name = ANY($%d) GROUP BY at.asset_id HAVING COUNT(DISTINCT t.name) = $%d)`, len(args)-1, len(args))
+	}
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, ok := decodeCursor(cursorParam, "newest", "timestamp")
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (created_at, id) < ($%d::timestamp, $%d::uuid)", len(args)-1, len(args))
+	}
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT %s, %s, created_at::text FROM assets %s ORDER BY created_at DESC, id DESC LIMIT $%d`,
+		assetColumns, assetUsageCount, where, len(args))
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+	defer rows.Close()
+
+	assets := []*models.Asset{}
+	var sortValues []string
+	for rows.Next() {
+		var usageCount int
+		var sortValue string
+		asset, err := scanAsset(rows, &usageCount, &sortValue)
+		if err != nil {
+			continue
+		}
+		asset.UsageCount = usageCount
+		assets = append(assets, asset)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	var nextCursor string
+	if len(assets) > limit {
+		assets = assets[:limit]
+		nextCursor = encodeCursor(pageCursor{Sort: "newest", Value: sortValues[limit-1], ID: assets[limit-1].ID})
+	}
+
+	if err := attachVariants(h.db, assets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+	if err := attachAssetTags(h.db, assets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets":      assets,
+		"next_cursor": nextCursor,
+	})
+}
+
+func (h *AssetHandler) GetAsset(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.respondWithAsset(c, http.StatusOK, c.Param("id"), userID.(string))
+}
+
+// GetAssetUsages lists the designs in an asset's workspace that reference
+// it.
+func (h *AssetHandler) GetAssetUsages(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var assetID, ownerID, hash string
+	var teamID *string
+	query := `SELECT id, owner_id, team_id, hash FROM assets WHERE id = $1 AND ` + workspaceAccess("owner_id", "team_id", "$2")
+	if err := h.db.QueryRow(query, c.Param("id"), userID).Scan(&assetID, &ownerID, &teamID, &hash); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	// Matches designs the same way as assetUsageCount
+	query = `SELECT id, title, updated_at FROM designs
+		WHERE asset_refs && ARRAY[$2, $3]::text[]
+		AND team_id IS NOT DISTINCT FROM $4 AND ($4 IS NOT NULL OR user_id = $1)
+		ORDER BY updated_at DESC`
+	rows, err := h.db.Query(query, ownerID, strings.ToLower(hash), strings.ToLower(assetID), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset usages"})
+		return
+	}
+	defer rows.Close()
+
+	usages := []models.AssetReference{}
+	for rows.Next() {
+		var ref models.AssetReference
+		if err := rows.Scan(&ref.DesignID, &ref.Title, &ref.UpdatedAt); err != nil {
+			continue
+		}
+		usages = append(usages, ref)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"designs": usages})
+}
+
+// UpdateAsset renames an asset, moves it between folders or replaces its
+// tags.
+func (h *AssetHandler) UpdateAsset(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	assetID := c.Param("id")
+
+	var req models.UpdateAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.FolderID != nil && *req.FolderID != "" {
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM asset_folders WHERE id = $1 AND owner_id = $2)`
+		if err := h.db.QueryRow(query, *req.FolderID, userID).Scan(&exists); err != nil || !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+			return
+		}
+	}
+
+	// The folder is only changed when folder_id was sent; "" clears it
+	query := `UPDATE assets SET original_name = COALESCE($1, original_name),
+		folder_id = CASE WHEN $2 THEN NULLIF($3, '')::uuid ELSE folder_id END
+		WHERE id = $4 AND ` + workspaceAccess("owner_id", "team_id", "$5")
+	folderID := ""
+	if req.FolderID != nil {
+		folderID = *req.FolderID
+	}
+	result, err := h.db.Exec(query, req.Name, req.FolderID != nil, folderID, assetID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update asset"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	if req.Tags != nil {
+		if err := replaceTags(h.db, "asset_tags", "asset_id", assetID, *req.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+			return
+		}
+	}
+
+	h.respondWithAsset(c, http.StatusOK, assetID, userID.(string))
+}
+
+// DeleteAsset removes an asset from the library. Assets still referenced by
+// a design are kept unless ?force=true is given. Stored files are only
+// removed once no other asset, variant or font uses them, and cached
+// renders once no asset has the same content.
+func (h *AssetHandler) DeleteAsset(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var hash string
+	var usageCount int
+	query := `SELECT hash, ` + assetUsageCount + ` FROM assets WHERE id = $1 AND ` + workspaceManage("owner_id", "team_id", "$2")
+	if err := h.db.QueryRow(query, c.Param("id"), userID).Scan(&hash, &usageCount); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	if usageCount > 0 && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Asset is used by designs", "usage_count": usageCount})
+		return
+	}
+
+	// Quarantined originals are removed along with the variants. Keys other
+	// rows still use are left in place.
+	query = `SELECT k.storage_key FROM (SELECT storage_key FROM asset_variants WHERE asset_id = $1
+			UNION SELECT storage_key FROM assets WHERE id = $1) k
+		WHERE NOT EXISTS (SELECT 1 FROM assets a WHERE a.storage_key = k.storage_key AND a.id <> $1)
+		AND NOT EXISTS (SELECT 1 FROM asset_variants v WHERE v.storage_key = k.storage_key AND v.asset_id <> $1)
+		AND NOT EXISTS (SELECT 1 FROM fonts f WHERE f.storage_key = k.storage_key)`
+	rows, err := h.db.Query(query, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete asset"})
+		return
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete asset"})
+			return
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+
+	if _, err := h.db.Exec(`DELETE FROM assets WHERE id = $1`, c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete asset"})
+		return
+	}
+
+	for _, key := range keys {
+		if err := h.store.Delete(c.Request.Context(), key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+	deleteRenders(c.Request.Context(), h.db, h.store, []string{hash})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Asset deleted successfully"})
+}
+
+func (h *AssetHandler) GetFolders(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := `SELECT f.id, f.name, COUNT(a.id), f.created_at FROM asset_folders f
+		LEFT JOIN assets a ON a.folder_id = f.id
+		WHERE f.owner_id = $1 GROUP BY f.id ORDER BY LOWER(f.name)`
+	rows, err := h.db.Query(query, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folders"})
+		return
+	}
+	defer rows.Close()
+
+	folders := []models.AssetFolder{}
+	for rows.Next() {
+		var folder models.AssetFolder
+		if err := rows.Scan(&folder.ID, &folder.Name, &folder.AssetCount, &folder.CreatedAt); err != nil {
+			continue
+		}
+		folders = append(folders, folder)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+func (h *AssetHandler) CreateFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.AssetFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folder models.AssetFolder
+	query := `INSERT INTO asset_folders (owner_id, name) VALUES ($1, $2) ON CONFLICT (owner_id, name) DO NOTHING
+		RETURNING id, name, created_at`
+	err := h.db.QueryRow(query, userID, strings.TrimSpace(req.Name)).Scan(&folder.ID, &folder.Name, &folder.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "A folder with this name already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func (h *AssetHandler) UpdateFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.AssetFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folder models.AssetFolder
+	query := `UPDATE asset_folders SET name = $1 WHERE id = $2 AND owner_id = $3
+		RETURNING id, name, (SELECT COUNT(*) FROM assets WHERE folder_id = asset_folders.id), created_at`
+	err := h.db.QueryRow(query, strings.TrimSpace(req.Name), c.Param("id"), userID).
+		Scan(&folder.ID, &folder.Name, &folder.AssetCount, &folder.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A folder with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder removes a folder; its assets move back to the library root.
+func (h *AssetHandler) DeleteFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM asset_folders WHERE id = $1 AND owner_id = $2`, c.Param("id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+// GetStorageUsage reports how much of their storage quota the user has used.
+func (h *AssetHandler) GetStorageUsage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	usage, err := storageUsage(h.db, userID.(string), h.defaultQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func (h *AssetHandler) respondWithAsset(c *gin.Context, status int, assetID, userID string) {
+	var usageCount int
+	query := `SELECT ` + assetColumns + `, ` + assetUsageCount + ` FROM assets WHERE id = $1 AND ` + workspaceAccess("owner_id", "team_id", "$2")
+	asset, err := scanAsset(h.db.QueryRow(query, assetID, userID), &usageCount)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	asset.UsageCount = usageCount
+
+	assets := []*models.Asset{asset}
+	if err := attachVariants(h.db, assets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset"})
+		return
+	}
+	if err := attachAssetTags(h.db, assets); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset"})
+		return
+	}
+
+	c.JSON(status, asset)
+}
+
+// storageUsage sums the stored variants of the user's assets, the
+// originals of those still in quarantine and the user's fonts. Users
+// without their own quota get defaultQuota.
+func storageUsage(db *sql.DB, userID string, defaultQuota int64) (*models.StorageUsage, error) {
+	usage := models.StorageUsage{}
+	query := `SELECT
+			(SELECT COALESCE(SUM(v.size), 0) FROM asset_variants v JOIN assets a ON a.id = v.asset_id WHERE a.owner_id = u.id)
+				+ (SELECT COALESCE(SUM(size), 0) FROM assets WHERE owner_id = u.id AND scan_status <> 'clean')
+				+ (SELECT COALESCE(SUM(size), 0) FROM fonts WHERE owner_id = u.id),
+			COALESCE(u.storage_quota_bytes, $2),
+			(SELECT COUNT(*) FROM assets WHERE owner_id = u.id)
+		FROM users u WHERE u.id = $1`
+	if err := db.QueryRow(query, userID, defaultQuota).Scan(&usage.UsedBytes, &usage.QuotaBytes, &usage.Assets); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// attachAssetTags loads the tags of every asset in one query.
+func attachAssetTags(db *sql.DB, assets []*models.Asset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.Asset, len(assets))
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		byID[asset.ID] = asset
+		ids = append(ids, asset.ID)
+	}
+
+	query := `SELECT at.asset_id, t.name FROM asset_tags at JOIN tags t ON t.id = at.tag_id WHERE at.asset_id = ANY($1::uuid[]) ORDER BY t.name`
+	rows, err := db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assetID, tag string
+		if err := rows.Scan(&assetID, &tag); err != nil {
+			return err
+		}
+		byID[assetID].Tags = append(byID[assetID].Tags, tag)
+	}
+	return rows.Err()
+}
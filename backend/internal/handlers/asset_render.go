@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/imaging"
+	"canvas-designer-backend/internal/storage"
+	"canvas-designer-backend/internal/svg"
+)
+
+// Derived renders are cached under their own prefix so /uploads never
+// exposes them without an access check.
+const derivedPrefix = "derived/"
+
+// Only these sizes and qualities may be requested, which bounds the number
+// of renders that can be cached per asset.
+var (
+	renderSizes     = map[int]bool{32: true, 64: true, 128: true, 256: true, 320: true, 480: true, 640: true, 800: true, 1024: true, 1280: true, 1600: true, 2048: true}
+	renderQualities = map[int]bool{50: true, 65: true, 75: true, 85: true, 95: true}
+	renderFits      = map[string]bool{"contain": true, "cover": true, "fill": true}
+)
+
+type renderOptions struct {
+	width   int
+	height  int
+	fit     string
+	format  imaging.Format
+	quality int
+}
+
+// RenderAsset transforms an asset on demand, e.g.
+// /api/assets/:id/render?w=320&h=200&fit=cover&format=webp. Renders are
+// cached in storage, so each combination is only produced once.
+func (h *AssetHandler) RenderAsset(c *gin.Context) {
+	var hash, sourceKey, mimeType, visibility string
+	var member bool
+	query := `SELECT hash, storage_key, mime_type, visibility, COALESCE(` + workspaceAccess("owner_id", "team_id", "NULLIF($2, '')::uuid") + `, FALSE)
+		FROM assets WHERE id = $1 AND scan_status = 'clean'`
+	if err := h.db.QueryRow(query, c.Param("id"), c.GetString("userID")).Scan(&hash, &sourceKey, &mimeType, &visibility, &member); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+	if visibility != "public" && !member {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	if mimeType == svg.MimeType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SVG assets are served as-is and cannot be rendered"})
+		return
+	}
+
+	opts, err := parseRenderOptions(c, imaging.Format(strings.TrimPrefix(mimeType, "image/")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := fmt.Sprintf("%s%s/w%d-h%d-%s-q%d%s", derivedPrefix, hash, opts.width, opts.height, opts.fit, opts.quality, opts.format.Ext())
+	data, err := h.cachedRender(c, key)
+	if err == storage.ErrNotFound {
+		data, err = h.render(c, hash, sourceKey, key, opts)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render asset"})
+		return
+	}
+
+	// The same key always renders the same bytes
+	cacheScope := "private"
+	if visibility == "public" {
+		cacheScope = "public"
+	}
+	c.Header("ETag", `"`+key[len(derivedPrefix):]+`"`)
+	c.Header("Cache-Control", cacheScope+", max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Type", opts.format.MimeType())
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(data))
+}
+
+func (h *AssetHandler) cachedRender(c *gin.Context, key string) ([]byte, error) {
+	obj, err := h.store.Get(c.Request.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	return io.ReadAll(obj.Body)
+}
+
+func (h *AssetHandler) render(c *gin.Context, hash, sourceKey, key string, opts renderOptions) ([]byte, error) {
+	obj, err := h.store.Get(c.Request.Context(), sourceKey)
+	if err != nil {
+		return nil, err
+	}
+	source, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	format, ok := imaging.Sniff(source)
+	if !ok {
+		return nil, imaging.ErrUnsupportedFormat
+	}
+	img, err := imaging.Decode(source, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, transform(img, opts), opts.format, opts.quality); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	// Recorded first so the render is found when its content is deleted,
+	// even if storing it fails halfway
+	if _, err := h.db.Exec(`INSERT INTO asset_renders (storage_key, hash) VALUES ($1, $2) ON CONFLICT DO NOTHING`, key, hash); err != nil {
+		return nil, err
+	}
+	if err := h.store.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), opts.format.MimeType()); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// deleteRenders removes the cached renders of each content hash that no
+// asset has any more.
+func deleteRenders(ctx context.Context, db *sql.DB, store storage.Storage, hashes []string) {
+	query := `DELETE FROM asset_renders WHERE hash = $1 AND NOT EXISTS (SELECT 1 FROM assets WHERE hash = $1) RETURNING storage_key`
+	for _, hash := range hashes {
+		rows, err := db.Query(query, hash)
+		if err != nil {
+			log.Printf("Failed to delete renders of %s: %v", hash, err)
+			continue
+		}
+		var keys []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err == nil {
+				keys = append(keys, key)
+			}
+		}
+		rows.Close()
+
+		for _, key := range keys {
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// transform resizes img as requested. When only one dimension is given the
+// other follows the aspect ratio; images are never enlarged beyond it.
+func transform(img image.Image, opts renderOptions) image.Image {
+	b := img.Bounds()
+	switch {
+	case opts.width == 0 && opts.height == 0:
+		return img
+	case opts.height == 0:
+		return imaging.Fit(img, opts.width, b.Dy())
+	case opts.width == 0:
+		return imaging.Fit(img, b.Dx(), opts.height)
+	}
+
+	switch opts.fit {
+	case "cover":
+		return imaging.Cover(img, opts.width, opts.height)
+	case "fill":
+		return imaging.Resize(img, opts.width, opts.height)
+	}
+	return imaging.Fit(img, opts.width, opts.height)
+}
+
+func parseRenderOptions(c *gin.Context, defaultFormat imaging.Format) (renderOptions, error) {
+	opts := renderOptions{fit: c.DefaultQuery("fit", "contain"), format: defaultFormat, quality: 85}
+
+	for _, dim := range []struct {
+		param string
+		dest  *int
+	}{{"w", &opts.width}, {"h", &opts.height}} {
+		value := c.Query(dim.param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || !renderSizes[n] {
+			return opts, fmt.Errorf("%s must be one of the allowed sizes: 32, 64, 128, 256, 320, 480, 640, 800, 1024, 1280, 1600, 2048", dim.param)
+		}
+		*dim.dest = n
+	}
+
+	if !renderFits[opts.fit] {
+		return opts, fmt.Errorf("fit must be contain, cover or fill")
+	}
+	if opts.width == 0 || opts.height == 0 {
+		opts.fit = "contain"
+	}
+
+	if value := c.Query("format"); value != "" {
+		switch imaging.Format(value) {
+		case imaging.JPEG, imaging.PNG, imaging.WebP:
+			opts.format = imaging.Format(value)
+		default:
+			return opts, fmt.Errorf("format must be jpeg, png or webp")
+		}
+	}
+
+	if value := c.Query("quality"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || !renderQualities[n] {
+			return opts, fmt.Errorf("quality must be one of 50, 65, 75, 85, 95")
+		}
+		opts.quality = n
+	}
+	// Quality only affects JPEG; normalise it so other formats share a cache entry
+	if opts.format != imaging.JPEG {
+		opts.quality = 0
+	}
+
+	return opts, nil
+}
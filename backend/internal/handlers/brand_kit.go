@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/fabric"
+	"canvas-designer-backend/internal/models"
+)
+
+const brandKitColumns = `id, owner_id, team_id, name, description, palettes, font_pairings, text_styles, created_at, updated_at`
+
+type BrandKitHandler struct {
+	db *sql.DB
+}
+
+func NewBrandKitHandler(db *sql.DB) *BrandKitHandler {
+	return &BrandKitHandler{db: db}
+}
+
+func (h *BrandKitHandler) GetBrandKits(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	args := []interface{}{userID}
+	scope, ok := workspaceScope(c, "owner_id", "team_id", "$1", &args)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+		return
+	}
+
+	query := `SELECT ` + brandKitColumns + ` FROM brand_kits WHERE ` + scope + ` ORDER BY LOWER(name)`
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch brand kits"})
+		return
+	}
+	defer rows.Close()
+
+	kits := []*models.BrandKit{}
+	for rows.Next() {
+		kit, err := scanBrandKit(rows)
+		if err != nil {
+			continue
+		}
+		kits = append(kits, kit)
+	}
+	if err := h.attachLogos(kits); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch brand kit logos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"brand_kits": kits})
+}
+
+func (h *BrandKitHandler) GetBrandKit(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.respondWithBrandKit(c, http.StatusOK, c.Param("id"), userID.(string))
+}
+
+func (h *BrandKitHandler) CreateBrandKit(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BrandKitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+	if !h.logosAvailable(c, req.Logos, userID.(string)) {
+		return
+	}
+
+	kitID, err := h.saveBrandKit("", userID.(string), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create brand kit"})
+		return
+	}
+
+	h.respondWithBrandKit(c, http.StatusCreated, kitID, userID.(string))
+}
+
+// UpdateBrandKit replaces a brand kit's contents.
+func (h *BrandKitHandler) UpdateBrandKit(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BrandKitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var found bool
+	h.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM brand_kits WHERE id = $1 AND `+workspaceAccess("owner_id", "team_id", "$2")+`)`, c.Param("id"), userID).Scan(&found)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+		return
+	}
+	if !h.logosAvailable(c, req.Logos, userID.(string)) {
+		return
+	}
+
+	if _, err := h.saveBrandKit(c.Param("id"), userID.(string), req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand kit"})
+		return
+	}
+
+	h.respondWithBrandKit(c, http.StatusOK, c.Param("id"), userID.(string))
+}
+
+func (h *BrandKitHandler) DeleteBrandKit(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM brand_kits WHERE id = $1 AND `+workspaceManage("owner_id", "team_id", "$2"), c.Param("id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete brand kit"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Brand kit deleted successfully"})
+}
+
+// CheckDesign reports the colors and fonts of a design that are not in a
+// brand kit's palettes, font pairings and text styles. The design is given
+// by design_id or, for unsaved work, as canvas_data.
+func (h *BrandKitHandler) CheckDesign(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BrandCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.DesignID == "") == (req.CanvasData == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either design_id or canvas_data"})
+		return
+	}
+
+	kit, err := scanBrandKit(h.db.QueryRow(`SELECT `+brandKitColumns+` FROM brand_kits WHERE id = $1 AND `+workspaceAccess("owner_id", "team_id", "$2"), c.Param("id"), userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+		return
+	}
+
+	canvas := req.CanvasData
+	if req.DesignID != "" {
+		var canvasData string
+		query := `SELECT canvas_data FROM designs WHERE id = $1 AND ` + designAccess("$2", false)
+		if err := h.db.QueryRow(query, req.DesignID, userID).Scan(&canvasData); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+			return
+		}
+		if err := json.Unmarshal([]byte(canvasData), &canvas); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read design"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": fabric.CheckBrand(canvas, brandRules(kit))})
+}
+
+// brandRules collects the colors and font families a kit allows.
+func brandRules(kit *models.BrandKit) fabric.BrandRules {
+	var rules fabric.BrandRules
+	for _, palette := range kit.Palettes {
+		rules.Colors = append(rules.Colors, palette.Colors...)
+	}
+	for _, pairing := range kit.FontPairings {
+		rules.Fonts = append(rules.Fonts, pairing.Heading.Family, pairing.Body.Family)
+	}
+	for _, style := range kit.TextStyles {
+		rules.Fonts = append(rules.Fonts, style.FontFamily)
+		if style.Fill != "" {
+			rules.Colors = append(rules.Colors, style.Fill)
+		}
+	}
+	return rules
+}
+
+// logosAvailable checks that every logo is a clean asset the user can
+// access, responding with 400 if not.
+func (h *BrandKitHandler) logosAvailable(c *gin.Context, logos []models.BrandLogo, userID string) bool {
+	if len(logos) == 0 {
+		return true
+	}
+	ids := make([]string, len(logos))
+	for i, logo := range logos {
+		ids[i] = logo.AssetID
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM assets WHERE id = ANY($1::uuid[]) AND ` + workspaceAccess("owner_id", "team_id", "$2") + ` AND scan_status = 'clean'`
+	if err := h.db.QueryRow(query, pq.Array(ids), userID).Scan(&count); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check logos"})
+		return false
+	}
+	if count != len(uniqueStrings(ids)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Logos must be uploaded assets"})
+		return false
+	}
+	return true
+}
+
+// saveBrandKit creates a kit when kitID is empty, or replaces its contents,
+// and returns its ID.
+func (h *BrandKitHandler) saveBrandKit(kitID, userID string, req models.BrandKitRequest) (string, error) {
+	// Omitted lists are stored as [] rather than null
+	if req.Palettes == nil {
+		req.Palettes = []models.BrandPalette{}
+	}
+	if req.FontPairings == nil {
+		req.FontPairings = []models.FontPairing{}
+	}
+	if req.TextStyles == nil {
+		req.TextStyles = []models.TextStyle{}
+	}
+	palettes, err := json.Marshal(req.Palettes)
+	if err != nil {
+		return "", err
+	}
+	pairings, err := json.Marshal(req.FontPairings)
+	if err != nil {
+		return "", err
+	}
+	styles, err := json.Marshal(req.TextStyles)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if kitID == "" {
+		query := `INSERT INTO brand_kits (owner_id, team_id, name, description, palettes, font_pairings, text_styles)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+		err = tx.QueryRow(query, userID, nullableID(req.TeamID), req.Name, req.Description, string(palettes), string(pairings), string(styles)).Scan(&kitID)
+	} else {
+		query := `UPDATE brand_kits SET name = $1, description = $2, palettes = $3, font_pairings = $4, text_styles = $5
+			WHERE id = $6 AND ` + workspaceAccess("owner_id", "team_id", "$7")
+		_, err = tx.Exec(query, req.Name, req.Description, string(palettes), string(pairings), string(styles), kitID, userID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM brand_kit_logos WHERE brand_kit_id = $1`, kitID); err != nil {
+		return "", err
+	}
+	for i, logo := range req.Logos {
+		query := `INSERT INTO brand_kit_logos (brand_kit_id, asset_id, name, position) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (brand_kit_id, asset_id) DO NOTHING`
+		if _, err := tx.Exec(query, kitID, logo.AssetID, logo.Name, i); err != nil {
+			return "", err
+		}
+	}
+
+	return kitID, tx.Commit()
+}
+
+func (h *BrandKitHandler) respondWithBrandKit(c *gin.Context, status int, kitID, userID string) {
+	kit, err := scanBrandKit(h.db.QueryRow(`SELECT `+brandKitColumns+` FROM brand_kits WHERE id = $1 AND `+workspaceAccess("owner_id", "team_id", "$2"), kitID, userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand kit not found"})
+		return
+	}
+	if err := h.attachLogos([]*models.BrandKit{kit}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch brand kit logos"})
+		return
+	}
+	c.JSON(status, gin.H{"brand_kit": kit})
+}
+
+// attachLogos loads the logos of every kit in one query.
+func (h *BrandKitHandler) attachLogos(kits []*models.BrandKit) error {
+	if len(kits) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.BrandKit, len(kits))
+	ids := make([]string, 0, len(kits))
+	for _, kit := range kits {
+		byID[kit.ID] = kit
+		ids = append(ids, kit.ID)
+	}
+
+	query := `SELECT l.brand_kit_id, l.asset_id, l.name, '/' || a.storage_key FROM brand_kit_logos l
+		JOIN assets a ON a.id = l.asset_id WHERE l.brand_kit_id = ANY($1::uuid[]) ORDER BY l.position`
+	rows, err := h.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kitID string
+		var logo models.BrandLogo
+		if err := rows.Scan(&kitID, &logo.AssetID, &logo.Name, &logo.URL); err != nil {
+			return err
+		}
+		byID[kitID].Logos = append(byID[kitID].Logos, logo)
+	}
+	return rows.Err()
+}
+
+func scanBrandKit(row rowScanner) (*models.BrandKit, error) {
+	var kit models.BrandKit
+	var palettes, pairings, styles string
+	err := row.Scan(&kit.ID, &kit.OwnerID, &kit.TeamID, &kit.Name, &kit.Description, &palettes, &pairings, &styles, &kit.CreatedAt, &kit.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(palettes), &kit.Palettes)
+	json.Unmarshal([]byte(pairings), &kit.FontPairings)
+	json.Unmarshal([]byte(styles), &kit.TextStyles)
+	kit.Logos = []models.BrandLogo{}
+	return &kit, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
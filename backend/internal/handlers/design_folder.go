@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/models"
+)
+
+const designFolderColumns = `id, name, parent_id, owner_id, team_id,
+	(SELECT COUNT(*) FROM designs d WHERE d.folder_id = design_folders.id),
+	(SELECT COUNT(*) FROM design_folders c WHERE c.parent_id = design_folders.id),
+	created_at, updated_at`
+
+// GetFolders lists design folders. parent_id narrows the list to the
+// subfolders of one folder ("root" for top-level folders); otherwise it
+// covers the workspace given by ?workspace=, where "shared" lists the
+// folders other users have shared with the caller.
+func (h *SimpleDesignHandler) GetFolders(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	args := []interface{}{userID}
+	parentID := c.Query("parent_id")
+	var where string
+	switch {
+	case parentID != "" && parentID != "root":
+		if !uuidPattern.MatchString(parentID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent_id"})
+			return
+		}
+		// Subfolders of a shared folder are shared too, whatever the workspace
+		args = append(args, parentID)
+		where = fmt.Sprintf("parent_id = $%d AND %s", len(args), folderAccess("$1"))
+	case c.Query("workspace") == "shared":
+		where = "id IN (SELECT folder_id FROM design_folder_shares WHERE user_id = $1)"
+	default:
+		scope, ok := workspaceScope(c, "owner_id", "team_id", "$1", &args)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+			return
+		}
+		where = scope
+		if parentID == "root" {
+			where += " AND parent_id IS NULL"
+		}
+	}
+
+	query := `SELECT ` + designFolderColumns + `, ` + folderRole("$1") + ` FROM design_folders WHERE ` + where + ` ORDER BY LOWER(name)`
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folders"})
+		return
+	}
+	defer rows.Close()
+
+	folders := []*models.DesignFolder{}
+	for rows.Next() {
+		var role string
+		folder, err := scanDesignFolder(rows, &role)
+		if err != nil {
+			continue
+		}
+		folder.Role = role
+		folders = append(folders, folder)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+// GetFolder returns a folder with its breadcrumb path.
+func (h *SimpleDesignHandler) GetFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.respondWithFolder(c, http.StatusOK, c.Param("id"), userID.(string))
+}
+
+// CreateFolder adds a folder at the root of a workspace or inside another
+// folder, whose workspace it joins.
+func (h *SimpleDesignHandler) CreateFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DesignFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder name is required"})
+		return
+	}
+
+	teamID := nullableID(req.TeamID)
+	if req.ParentID != nil && *req.ParentID != "" {
+		var parentTeamID *string
+		query := `SELECT team_id FROM design_folders WHERE id = $1 AND ` + workspaceAccess("owner_id", "team_id", "$2")
+		if err := h.db.QueryRow(query, *req.ParentID, userID).Scan(&parentTeamID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+			return
+		}
+		teamID = nil
+		if parentTeamID != nil {
+			teamID = *parentTeamID
+		}
+	} else if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+
+	var folderID string
+	query := `INSERT INTO design_folders (owner_id, team_id, parent_id, name) VALUES ($1, $2, NULLIF($3, '')::uuid, $4) RETURNING id`
+	err := h.db.QueryRow(query, userID, teamID, stringValue(req.ParentID), name).Scan(&folderID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A folder with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder"})
+		return
+	}
+
+	h.respondWithFolder(c, http.StatusCreated, folderID, userID.(string))
+}
+
+// UpdateFolder renames a folder or moves it under another folder in the
+// same workspace.
+func (h *SimpleDesignHandler) UpdateFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	folderID := c.Param("id")
+
+	var req models.UpdateDesignFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder name is required"})
+			return
+		}
+		req.Name = &name
+	}
+
+	var teamID *string
+	query := `SELECT team_id FROM design_folders WHERE id = $1 AND ` + workspaceManage("owner_id", "team_id", "$2")
+	if err := h.db.QueryRow(query, folderID, userID).Scan(&teamID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	if parentID := stringValue(req.ParentID); parentID != "" {
+		if !h.folderInWorkspace(parentID, teamID, userID.(string)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent folder not found"})
+			return
+		}
+		// A folder cannot be moved into itself or one of its subfolders
+		var cycle bool
+		query := `WITH RECURSIVE subtree AS (
+				SELECT id FROM design_folders WHERE id = $1
+				UNION SELECT f.id FROM design_folders f JOIN subtree s ON f.parent_id = s.id
+			) SELECT EXISTS(SELECT 1 FROM subtree WHERE id = $2)`
+		if err := h.db.QueryRow(query, folderID, parentID).Scan(&cycle); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+			return
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A folder cannot be moved into itself or its subfolders"})
+			return
+		}
+	}
+
+	// The parent is only changed when parent_id was sent; "" moves the
+	// folder to the root
+	query = `UPDATE design_folders SET name = COALESCE($1, name),
+		parent_id = CASE WHEN $2 THEN NULLIF($3, '')::uuid ELSE parent_id END
+		WHERE id = $4`
+	if _, err := h.db.Exec(query, req.Name, req.ParentID != nil, stringValue(req.ParentID), folderID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A folder with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update folder"})
+		return
+	}
+
+	h.respondWithFolder(c, http.StatusOK, folderID, userID.(string))
+}
+
+// DeleteFolder removes a folder; its designs and subfolders move up to its
+// parent.
+func (h *SimpleDesignHandler) DeleteFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	folderID := c.Param("id")
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+	defer tx.Rollback()
+
+	var parentID *string
+	query := `SELECT parent_id FROM design_folders WHERE id = $1 AND ` + workspaceManage("owner_id", "team_id", "$2") + ` FOR UPDATE`
+	if err := tx.QueryRow(query, folderID, userID).Scan(&parentID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	statements := []string{
+		`UPDATE design_folders SET parent_id = $2 WHERE parent_id = $1`,
+		`UPDATE designs SET folder_id = $2 WHERE folder_id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, folderID, parentID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": "A subfolder has the same name as a folder in the parent"})
+				return
+			}
+			log.Printf("Failed to delete folder %s: %v", folderID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+			return
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM design_folders WHERE id = $1`, folderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+// MoveDesign files a design in a folder of its own workspace, or at the
+// root.
+func (h *SimpleDesignHandler) MoveDesign(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	designID := c.Param("id")
+
+	var req models.MoveDesignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ownerID string
+	var teamID *string
+	query := `SELECT user_id, team_id FROM designs WHERE id = $1 AND ` + workspaceAccess("user_id", "team_id", "$2")
+	if err := h.db.QueryRow(query, designID, userID).Scan(&ownerID, &teamID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
+	}
+
+	folderID := stringValue(req.FolderID)
+	if folderID != "" && !h.folderInWorkspace(folderID, teamID, ownerID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	if _, err := h.db.Exec(`UPDATE designs SET folder_id = $1 WHERE id = $2`, nullableID(folderID), designID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move design"})
+		return
+	}
+
+	h.respondWithDesign(c, http.StatusOK, designID, userID.(string))
+}
+
+// GetFolderShares lists who a folder is shared with.
+func (h *SimpleDesignHandler) GetFolderShares(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !h.requireFolderManage(c, c.Param("id"), userID.(string)) {
+		return
+	}
+
+	query := `SELECT s.user_id, u.email, u.name, s.role, s.created_at FROM design_folder_shares s
+		JOIN users u ON u.id = s.user_id WHERE s.folder_id = $1 ORDER BY s.created_at`
+	rows, err := h.db.Query(query, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folder shares"})
+		return
+	}
+	defer rows.Close()
+
+	shares := []models.FolderShare{}
+	for rows.Next() {
+		var share models.FolderShare
+		if err := rows.Scan(&share.UserID, &share.Email, &share.Name, &share.Role, &share.CreatedAt); err != nil {
+			continue
+		}
+		shares = append(shares, share)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// ShareFolder gives another user viewer or editor access to a folder, its
+// subfolders and every design inside them. Sharing again changes the role.
+func (h *SimpleDesignHandler) ShareFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	folderID := c.Param("id")
+	if !h.requireFolderManage(c, folderID, userID.(string)) {
+		return
+	}
+
+	var req models.ShareFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = "viewer"
+	}
+
+	var share models.FolderShare
+	err := h.db.QueryRow(`SELECT id, email, name FROM users WHERE email = $1 AND deletion_scheduled_at IS NULL`,
+		strings.ToLower(strings.TrimSpace(req.Email))).Scan(&share.UserID, &share.Email, &share.Name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No user with this email address"})
+		return
+	}
+	if share.UserID == userID.(string) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot share a folder with yourself"})
+		return
+	}
+
+	query := `INSERT INTO design_folder_shares (folder_id, user_id, role, granted_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (folder_id, user_id) DO UPDATE SET role = EXCLUDED.role, granted_by = EXCLUDED.granted_by
+		RETURNING role, created_at`
+	if err := h.db.QueryRow(query, folderID, share.UserID, req.Role, userID).Scan(&share.Role, &share.CreatedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share folder"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "folder.shared", TargetID: folderID, IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID"), Metadata: map[string]interface{}{"user_id": share.UserID, "role": share.Role}})
+	c.JSON(http.StatusOK, gin.H{"share": share})
+}
+
+func (h *SimpleDesignHandler) UnshareFolder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	folderID := c.Param("id")
+	if !h.requireFolderManage(c, folderID, userID.(string)) {
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM design_folder_shares WHERE folder_id = $1 AND user_id = $2`, folderID, c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove folder share"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "folder.unshared", TargetID: folderID, IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID"), Metadata: map[string]interface{}{"user_id": c.Param("userId")}})
+	c.JSON(http.StatusOK, gin.H{"message": "Folder share removed successfully"})
+}
+
+func (h *SimpleDesignHandler) respondWithFolder(c *gin.Context, status int, folderID, userID string) {
+	var role string
+	query := `SELECT ` + designFolderColumns + `, ` + folderRole("$2") + ` FROM design_folders WHERE id = $1 AND ` + folderAccess("$2")
+	folder, err := scanDesignFolder(h.db.QueryRow(query, folderID, userID), &role)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+	folder.Role = role
+
+	if folder.Path, err = folderPath(h.db, folderID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folder path"})
+		return
+	}
+
+	c.JSON(status, gin.H{"folder": folder})
+}
+
+// requireFolderManage responds with 404 unless the user may rename, move,
+// delete or share the folder.
+func (h *SimpleDesignHandler) requireFolderManage(c *gin.Context, folderID, userID string) bool {
+	var found bool
+	query := `SELECT EXISTS(SELECT 1 FROM design_folders WHERE id = $1 AND ` + workspaceManage("owner_id", "team_id", "$2") + `)`
+	if err := h.db.QueryRow(query, folderID, userID).Scan(&found); err != nil || !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return false
+	}
+	return true
+}
+
+// folderInWorkspace reports whether a folder belongs to the given team, or
+// to ownerID's personal space when teamID is nil.
+func (h *SimpleDesignHandler) folderInWorkspace(folderID string, teamID *string, ownerID string) bool {
+	var found bool
+	query := `SELECT EXISTS(SELECT 1 FROM design_folders WHERE id = $1 AND team_id IS NOT DISTINCT FROM $2
+		AND (team_id IS NOT NULL OR owner_id = $3))`
+	h.db.QueryRow(query, folderID, teamID, ownerID).Scan(&found)
+	return found
+}
+
+// folderPath returns the breadcrumb trail from the root down to folderID,
+// leaving out ancestors the user cannot open (above a shared folder).
+func folderPath(db *sql.DB, folderID, userID string) ([]models.FolderCrumb, error) {
+	query := `WITH RECURSIVE path AS (
+			SELECT id, name, parent_id, owner_id, team_id, 0 AS depth FROM design_folders WHERE id = $1
+			UNION ALL
+			SELECT f.id, f.name, f.parent_id, f.owner_id, f.team_id, p.depth + 1 FROM design_folders f JOIN path p ON f.id = p.parent_id
+		) SELECT id, name FROM path WHERE ` + folderAccess("$2") + ` ORDER BY depth DESC`
+	rows, err := db.Query(query, folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	path := []models.FolderCrumb{}
+	for rows.Next() {
+		var crumb models.FolderCrumb
+		if err := rows.Scan(&crumb.ID, &crumb.Name); err != nil {
+			return nil, err
+		}
+		path = append(path, crumb)
+	}
+	return path, rows.Err()
+}
+
+// sharedFolders returns a subquery of the folders shared with the user
+// given by param, directly or through a shared ancestor. With edit set,
+// only editor shares count.
+func sharedFolders(param string, edit bool) string {
+	role := ""
+	if edit {
+		role = " AND role = 'editor'"
+	}
+	return fmt.Sprintf(`(WITH RECURSIVE shared AS (
+			SELECT folder_id AS id FROM design_folder_shares WHERE user_id = %s%s
+			UNION SELECT f.id FROM design_folders f JOIN shared s ON f.parent_id = s.id
+		) SELECT id FROM shared)`, param, role)
+}
+
+// folderAccess returns a condition matching folders the user given by
+// param can open: those in their workspaces and those shared with them.
+func folderAccess(param string) string {
+	return fmt.Sprintf("(%s OR id IN %s)", workspaceAccess("owner_id", "team_id", param), sharedFolders(param, false))
+}
+
+// folderRole returns the caller's role on a folder for DesignFolder.Role.
+func folderRole(param string) string {
+	return fmt.Sprintf("CASE WHEN %s THEN 'owner' WHEN id IN %s THEN 'editor' ELSE 'viewer' END",
+		workspaceAccess("owner_id", "team_id", param), sharedFolders(param, true))
+}
+
+// designAccess returns a condition matching designs the user given by
+// param can open: those in their workspaces and those inside folders shared
+// with them. With edit set, viewer shares are not enough.
+func designAccess(param string, edit bool) string {
+	return fmt.Sprintf("(%s OR folder_id IN %s)", workspaceAccess("user_id", "team_id", param), sharedFolders(param, edit))
+}
+
+func scanDesignFolder(row rowScanner, extra ...interface{}) (*models.DesignFolder, error) {
+	var folder models.DesignFolder
+	dest := []interface{}{&folder.ID, &folder.Name, &folder.ParentID, &folder.OwnerID, &folder.TeamID,
+		&folder.DesignCount, &folder.FolderCount, &folder.CreatedAt, &folder.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/models"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// StarDesign adds a design the user can open to their starred designs.
+func (h *SimpleDesignHandler) StarDesign(c *gin.Context) {
+	h.setStarred(c, true)
+}
+
+// UnstarDesign removes a design from the user's starred designs.
+func (h *SimpleDesignHandler) UnstarDesign(c *gin.Context) {
+	h.setStarred(c, false)
+}
+
+func (h *SimpleDesignHandler) setStarred(c *gin.Context, starred bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	designID := c.Param("id")
+	var found bool
+	err := h.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM designs WHERE id = $1 AND `+designAccess("$2", false)+`)`, designID, userID).Scan(&found)
+	if err != nil || !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
+	}
+
+	if starred {
+		_, err = h.db.Exec(`INSERT INTO design_stars (user_id, design_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, designID)
+	} else {
+		_, err = h.db.Exec(`DELETE FROM design_stars WHERE user_id = $1 AND design_id = $2`, userID, designID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update star"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"starred": starred})
+}
+
+// GetRecentDesigns lists the designs the user most recently created, opened
+// or edited that they can still open, newest first.
+func (h *SimpleDesignHandler) GetRecentDesigns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := parseLimit(c, 20, 50)
+	query := `SELECT ` + designListColumns + `, ` + designStarred("$1") + `, r.accessed_at FROM designs
+		JOIN (SELECT design_id, accessed_at FROM recent_designs WHERE user_id = $1) r ON r.design_id = designs.id
+		WHERE ` + designAccess("$1", false) + ` ORDER BY r.accessed_at DESC LIMIT $2`
+	rows, err := h.db.Query(query, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recent designs"})
+		return
+	}
+	defer rows.Close()
+
+	designs := []models.Design{}
+	for rows.Next() {
+		var starred bool
+		var accessedAt sql.NullTime
+		design, err := scanDesign(rows, &starred, &accessedAt)
+		if err != nil {
+			continue
+		}
+		design.Starred = starred
+		if accessedAt.Valid {
+			design.AccessedAt = &accessedAt.Time
+		}
+		designs = append(designs, *design)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"designs": designs})
+}
+
+// touchRecentDesign records that the user just worked on a design. A
+// failure only affects the recent list, so callers outside a transaction
+// may ignore the error; it is logged here.
+func touchRecentDesign(db execer, userID, designID string) error {
+	_, err := db.Exec(`INSERT INTO recent_designs (user_id, design_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, design_id) DO UPDATE SET accessed_at = NOW()`, userID, designID)
+	if err != nil {
+		log.Printf("Failed to record recent design %s: %v", designID, err)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/models"
+)
+
+// Headline options wrap matches in <mark>; snippets hold up to two
+// fragments of the description and canvas text.
+const (
+	titleHeadlineOptions   = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+	snippetHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20, FragmentDelimiter=\" … \""
+)
+
+// SearchDesigns runs a full-text search (websearch syntax) over the titles,
+// descriptions and canvas text of every design the user can open, best
+// matches first.
+func (h *SimpleDesignHandler) SearchDesigns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, offset := parseLimitOffset(c, 20, 100)
+
+	from := `FROM designs, websearch_to_tsquery('english', $1) query WHERE search_vector @@ query AND ` + designAccess("$2", false)
+	var total int
+	if err := h.db.QueryRow(`SELECT COUNT(*) `+from, q, userID).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search designs"})
+		return
+	}
+
+	// Text is HTML-escaped before highlighting so only the <mark> tags are
+	// markup; the default parser reads the entities as single tokens
+	query := fmt.Sprintf(`SELECT %s, %s,
+			ts_headline('english', %s, query, '%s'),
+			ts_headline('english', %s, query, '%s'),
+			ts_rank(search_vector, query)::float8 AS rank
+		%s ORDER BY rank DESC, updated_at DESC, id LIMIT $3 OFFSET $4`,
+		designListColumns, designStarred("$2"),
+		escapeHTMLColumn("title"), titleHeadlineOptions,
+		escapeHTMLColumn(`CONCAT_WS(E'\n', description, canvas_text)`), snippetHeadlineOptions,
+		from)
+	rows, err := h.db.Query(query, q, userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search designs"})
+		return
+	}
+	defer rows.Close()
+
+	results := []models.DesignSearchResult{}
+	for rows.Next() {
+		var result models.DesignSearchResult
+		var starred bool
+		design, err := scanDesign(rows, &starred, &result.TitleHighlight, &result.Snippet, &result.Rank)
+		if err != nil {
+			continue
+		}
+		design.Starred = starred
+		result.Design = *design
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "total": total})
+}
+
+// escapeHTMLColumn returns an expression escaping the HTML special
+// characters of a text expression.
+func escapeHTMLColumn(expr string) string {
+	return fmt.Sprintf(`REPLACE(REPLACE(REPLACE(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`, expr)
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/fonts"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/render"
+	"canvas-designer-backend/internal/scanner"
+	"canvas-designer-backend/internal/storage"
+)
+
+const (
+	maxFontSize = 10 * 1024 * 1024
+	// Font URLs are loaded by @font-face rules for as long as the editor is
+	// open, so they outlive ordinary signed URLs
+	fontURLTTL = 24 * time.Hour
+)
+
+const fontColumns = `id, owner_id, team_id, family, weight, style, format, original_name, size, hash, storage_key, created_at`
+
+type FontHandler struct {
+	db           *sql.DB
+	store        storage.Storage
+	scanner      scanner.Scanner
+	defaultQuota int64
+}
+
+func NewFontHandler(db *sql.DB, store storage.Storage, scanner scanner.Scanner, defaultQuota int64) *FontHandler {
+	return &FontHandler{db: db, store: store, scanner: scanner, defaultQuota: defaultQuota}
+}
+
+// UploadFont adds a TTF, OTF or WOFF2 file to the user's font library, or a
+// team's when the team_id form field is set. The family, weight and style
+// are read from the font itself. Font files count towards the uploader's
+// storage quota and, unlike images, are never public.
+func (h *FontHandler) UploadFont(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	file, err := c.FormFile("font")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	teamID := c.PostForm("team_id")
+	if teamID != "" && !uuidPattern.MatchString(teamID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team_id"})
+		return
+	}
+	if !requireTeamMember(c, h.db, teamID, userID.(string)) {
+		return
+	}
+	if file.Size > maxFontSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum size is 10MB"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	info, err := fonts.Parse(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	// Uploading the same file twice returns the existing font
+	query := `SELECT ` + fontColumns + ` FROM fonts WHERE owner_id = $1 AND team_id IS NOT DISTINCT FROM $2 AND hash = $3`
+	if font, key, err := scanFont(h.db.QueryRow(query, userID, nullableID(teamID), hash)); err == nil {
+		h.respondWithFont(c, http.StatusOK, font, key)
+		return
+	}
+
+	usage, err := storageUsage(h.db, userID.(string), h.defaultQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	if usage.UsedBytes+int64(len(data)) > usage.QuotaBytes {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Storage quota exceeded", "usage": usage})
+		return
+	}
+
+	// Fonts are small, so they are scanned before anything is stored
+	result, err := h.scanner.Scan(c.Request.Context(), bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Failed to scan font: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "File could not be scanned, please try again later"})
+		return
+	}
+	if result.Infected {
+		log.Printf("Font upload rejected by scanner: %s", result.Signature)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "File was rejected by the malware scanner"})
+		return
+	}
+
+	key := uploadsPrefix + hash + "-font" + info.Format.Ext()
+	if err := h.store.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), info.Format.MimeType()); err != nil {
+		log.Printf("Failed to store font: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	query = `INSERT INTO fonts (owner_id, team_id, family, weight, style, format, original_name, size, hash, storage_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING ` + fontColumns
+	font, _, err := scanFont(h.db.QueryRow(query, userID, nullableID(teamID), info.Family, info.Weight, info.Style, string(info.Format),
+		file.Filename, len(data), hash, key))
+	if err != nil {
+		h.deleteUnusedFont(c.Request.Context(), key)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A font with this family, weight and style already exists",
+				"family": info.Family, "weight": info.Weight, "style": info.Style})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record font"})
+		return
+	}
+
+	h.respondWithFont(c, http.StatusCreated, font, key)
+}
+
+// GetFonts lists the fonts in the user's space and teams, narrowed by
+// ?workspace=, with URLs that can be used in @font-face rules without
+// credentials.
+func (h *FontHandler) GetFonts(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	args := []interface{}{userID}
+	scope, ok := workspaceScope(c, "owner_id", "team_id", "$1", &args)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+		return
+	}
+	query := `SELECT ` + fontColumns + ` FROM fonts WHERE ` + scope
+	if family := strings.TrimSpace(c.Query("family")); family != "" {
+		args = append(args, family)
+		query += fmt.Sprintf(` AND LOWER(family) = LOWER($%d)`, len(args))
+	}
+	query += ` ORDER BY LOWER(family), weight, style`
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fonts"})
+		return
+	}
+	defer rows.Close()
+
+	list := []*models.Font{}
+	for rows.Next() {
+		font, key, err := scanFont(rows)
+		if err != nil {
+			continue
+		}
+		if font.URL, err = h.store.SignedURL(c.Request.Context(), key, fontURLTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign font URL"})
+			return
+		}
+		list = append(list, font)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fonts": list})
+}
+
+func (h *FontHandler) DeleteFont(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var key string
+	query := `DELETE FROM fonts WHERE id = $1 AND ` + workspaceManage("owner_id", "team_id", "$2") + ` RETURNING storage_key`
+	if err := h.db.QueryRow(query, c.Param("id"), userID).Scan(&key); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Font not found"})
+		return
+	}
+
+	h.deleteUnusedFont(c.Request.Context(), key)
+	c.JSON(http.StatusOK, gin.H{"message": "Font deleted successfully"})
+}
+
+// deleteUnusedFont removes a font file unless another user uploaded the
+// same file.
+func (h *FontHandler) deleteUnusedFont(ctx context.Context, key string) {
+	var shared bool
+	h.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM fonts WHERE storage_key = $1)`, key).Scan(&shared)
+	if !shared {
+		if err := h.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+func (h *FontHandler) respondWithFont(c *gin.Context, status int, font *models.Font, key string) {
+	url, err := h.store.SignedURL(c.Request.Context(), key, fontURLTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign font URL"})
+		return
+	}
+	font.URL = url
+	c.JSON(status, font)
+}
+
+// loadFontFaces reads the fonts for the given families from the user's
+// space and teams, for embedding into server-side renders. Families not in the library are
+// skipped; the renderer falls back to system fonts for them.
+func loadFontFaces(ctx context.Context, db *sql.DB, store storage.Storage, userID string, families []string) ([]render.FontFace, error) {
+	if len(families) == 0 {
+		return nil, nil
+	}
+	lower := make([]string, len(families))
+	for i, family := range families {
+		lower[i] = strings.ToLower(family)
+	}
+
+	query := `SELECT ` + fontColumns + ` FROM fonts WHERE ` + workspaceAccess("owner_id", "team_id", "$1") + ` AND LOWER(family) = ANY($2)`
+	rows, err := db.Query(query, userID, pq.Array(lower))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var faces []render.FontFace
+	for rows.Next() {
+		font, key, err := scanFont(rows)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(obj.Body)
+		obj.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		format := fonts.Format(font.Format)
+		faces = append(faces, render.FontFace{
+			Family:   font.Family,
+			Weight:   font.Weight,
+			Style:    font.Style,
+			Format:   string(format),
+			MimeType: format.MimeType(),
+			Data:     data,
+		})
+	}
+	return faces, rows.Err()
+}
+
+// scanFont returns a font and its storage key.
+func scanFont(row rowScanner) (*models.Font, string, error) {
+	var font models.Font
+	var key string
+	err := row.Scan(&font.ID, &font.OwnerID, &font.TeamID, &font.Family, &font.Weight, &font.Style, &font.Format,
+		&font.OriginalName, &font.Size, &font.Hash, &key, &font.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &font, key, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageCursor marks the last row of a page: the value of the sort column and
+// the row ID as a tie-breaker. It is opaque to clients.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor for the given sort.
+// The value must be valid for cast, the SQL type it is compared as, so a
+// forged cursor is rejected here rather than by the database.
+func decodeCursor(value, sort, cast string) (*pageCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || !uuidPattern.MatchString(cursor.ID) {
+		return nil, false
+	}
+	if !validCursorValue(cursor.Value, cast) {
+		return nil, false
+	}
+	return &cursor, true
+}
+
+// validCursorValue reports whether value, as Postgres prints the sort
+// column as text, parses as cast.
+func validCursorValue(value, cast string) bool {
+	var err error
+	switch cast {
+	case "timestamp":
+		_, err = time.Parse("2006-01-02 15:04:05.999999", value)
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "float8":
+		_, err = strconv.ParseFloat(value, 64)
+	case "text":
+		return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+	default:
+		return false
+	}
+	return err == nil
+}
+
+// containsPattern turns a search term into an ILIKE pattern matching it
+// anywhere, with its own wildcards escaped. Use it with ESCAPE '\'.
+func containsPattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
+
+// parseLimit reads the limit query parameter, clamping it to max.
+func parseLimit(c *gin.Context, defaultLimit, max int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit
+}
+
+// parseLimitOffset reads limit/offset query parameters, clamping limit to max.
+func parseLimitOffset(c *gin.Context, defaultLimit, max int) (int, int) {
+	limit := parseLimit(c, defaultLimit, max)
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
@@ -2,24 +2,67 @@ package handlers
 
 import (
 	"database/sql"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/mailer"
 	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/ratelimit"
+	"canvas-designer-backend/internal/storage"
 	"canvas-designer-backend/internal/utils"
 )
 
 type SimpleAuthHandler struct {
-	db *sql.DB
+	db              *sql.DB
+	loginLimiter    *ratelimit.Limiter
+	registerLimiter *ratelimit.Limiter
+	mailer          mailer.Mailer
+	store           storage.Storage
+	appURL          string
 }
 
-func NewSimpleAuthHandler(db *sql.DB) *SimpleAuthHandler {
-	return &SimpleAuthHandler{db: db}
+func NewSimpleAuthHandler(db *sql.DB, attempts ratelimit.Store, mail mailer.Mailer, store storage.Storage, appURL string) *SimpleAuthHandler {
+	return &SimpleAuthHandler{
+		db:     db,
+		mailer: mail,
+		store:  store,
+		appURL: appURL,
+		loginLimiter: ratelimit.NewLimiter(attempts, ratelimit.Policy{
+			Threshold:   5,
+			Window:      15 * time.Minute,
+			BaseLockout: 30 * time.Second,
+			MaxLockout:  time.Hour,
+		}),
+		registerLimiter: ratelimit.NewLimiter(attempts, ratelimit.Policy{
+			Threshold:   10,
+			Window:      time.Hour,
+			BaseLockout: 5 * time.Minute,
+			MaxLockout:  24 * time.Hour,
+		}),
+	}
 }
 
 func (h *SimpleAuthHandler) Register(c *gin.Context) {
+	ipKey := "register:ip:" + c.ClientIP()
+	if h.rejectIfLocked(c, h.registerLimiter, ipKey) {
+		return
+	}
+
+	// Every registration attempt counts, successful or not
+	result, err := h.registerLimiter.Record(ipKey)
+	if err != nil {
+		log.Printf("Failed to record registration attempt: %v", err)
+	} else if result.Locked {
+		h.auditLockout(c, ipKey, "", result)
+	}
+
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -58,22 +101,41 @@ func (h *SimpleAuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ipKey := "login:ip:" + c.ClientIP()
+	accountKey := "login:account:" + strings.ToLower(req.Email)
+	if h.rejectIfLocked(c, h.loginLimiter, ipKey) || h.rejectIfLocked(c, h.loginLimiter, accountKey) {
+		return
+	}
+
 	// Get user from database
-	query := `SELECT id, email, password, name FROM users WHERE email = $1`
+	query := `SELECT id, email, password, name, role, suspended_at FROM users WHERE email = $1`
 	var user models.User
 	var hashedPassword string
-	err := h.db.QueryRow(query, req.Email).Scan(&user.ID, &user.Email, &hashedPassword, &user.Name)
+	err := h.db.QueryRow(query, req.Email).Scan(&user.ID, &user.Email, &hashedPassword, &user.Name, &user.Role, &user.SuspendedAt)
 	if err != nil {
+		h.recordLoginFailure(c, ipKey, accountKey, "")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(c, ipKey, accountKey, user.ID)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+		return
+	}
+
+	// Only the account counter is cleared so one valid login cannot reset
+	// the counter of an IP that is guessing other accounts
+	if err := h.loginLimiter.Reset(accountKey); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
 	// Generate JWT
 	token, err := utils.GenerateJWT(user.ID)
 	if err != nil {
@@ -94,9 +156,9 @@ func (h *SimpleAuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, email, name, created_at, updated_at FROM users WHERE id = $1`
+	query := `SELECT id, email, name, role, created_at, updated_at, deletion_scheduled_at FROM users WHERE id = $1`
 	var user models.User
-	err := h.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	err := h.db.QueryRow(query, userID).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.DeletionScheduledAt)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -130,3 +192,50 @@ func (h *SimpleAuthHandler) UpdateProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
+
+// rejectIfLocked responds with 429 and a Retry-After header when key is
+// locked out. It returns true if the request was rejected.
+func (h *SimpleAuthHandler) rejectIfLocked(c *gin.Context, limiter *ratelimit.Limiter, key string) bool {
+	retryAfter, err := limiter.Allow(key)
+	if err != nil {
+		log.Printf("Failed to check attempts for %s: %v", key, err)
+		return false
+	}
+	if retryAfter <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many attempts. Please try again later",
+		"retry_after": seconds,
+	})
+	return true
+}
+
+func (h *SimpleAuthHandler) recordLoginFailure(c *gin.Context, ipKey, accountKey, userID string) {
+	for _, key := range []string{ipKey, accountKey} {
+		result, err := h.loginLimiter.Record(key)
+		if err != nil {
+			log.Printf("Failed to record login attempt for %s: %v", key, err)
+			continue
+		}
+		if result.Locked {
+			h.auditLockout(c, key, userID, result)
+		}
+	}
+}
+
+func (h *SimpleAuthHandler) auditLockout(c *gin.Context, key, userID string, result ratelimit.Result) {
+	audit.Record(h.db, audit.Entry{
+		Action:    "auth.lockout",
+		TargetID:  userID,
+		IPAddress: c.ClientIP(),
+		Metadata: map[string]interface{}{
+			"key":                 key,
+			"attempts":            result.Count,
+			"retry_after_seconds": int(math.Ceil(result.RetryAfter.Seconds())),
+		},
+	})
+}
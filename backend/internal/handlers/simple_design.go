@@ -5,11 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/fabric"
 	"canvas-designer-backend/internal/models"
 )
 
+const designColumns = `id, title, COALESCE(description, ''), COALESCE(canvas_data::text, ''), COALESCE(thumbnail, ''),
+	user_id, team_id, folder_id, template_id, created_at, updated_at`
+
+// designListColumns matches designColumns but leaves canvas_data out of
+// list responses.
+const designListColumns = `id, title, COALESCE(description, ''), '', COALESCE(thumbnail, ''),
+	user_id, team_id, folder_id, template_id, created_at, updated_at`
+
 type SimpleDesignHandler struct {
 	db *sql.DB
 }
@@ -18,6 +31,25 @@ func NewSimpleDesignHandler(db *sql.DB) *SimpleDesignHandler {
 	return &SimpleDesignHandler{db: db}
 }
 
+// designSorts maps the sort query parameter to the column used for ordering
+// and cursors. Ties are broken by id in the same direction.
+var designSorts = map[string]struct {
+	column string
+	cast   string
+	desc   bool
+}{
+	"updated":      {"updated_at", "timestamp", true},
+	"newest":       {"created_at", "timestamp", true},
+	"oldest":       {"created_at", "timestamp", false},
+	"alphabetical": {"LOWER(title)", "text", false},
+}
+
+// GetDesigns lists designs, most recently updated first, paged with
+// next_cursor. folder_id narrows the list to one folder ("root" for unfiled
+// designs), including folders shared with the caller; otherwise it covers
+// the workspace given by ?workspace=, where "shared" lists designs in
+// folders shared with the caller. The list leaves out canvas_data unless
+// include=canvas_data is passed.
 func (h *SimpleDesignHandler) GetDesigns(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -25,30 +57,139 @@ func (h *SimpleDesignHandler) GetDesigns(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, title, description, canvas_data, thumbnail, created_at, updated_at FROM designs WHERE user_id = $1 ORDER BY updated_at DESC`
-	rows, err := h.db.Query(query, userID)
+	limit := parseLimit(c, 50, 100)
+	sortName := c.DefaultQuery("sort", "updated")
+	sort, ok := designSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use updated, newest, oldest or alphabetical"})
+		return
+	}
+
+	args := []interface{}{userID}
+	folderID := c.Query("folder_id")
+	var where string
+	switch {
+	case folderID != "" && folderID != "root":
+		if !uuidPattern.MatchString(folderID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder_id"})
+			return
+		}
+		args = append(args, folderID)
+		where = fmt.Sprintf("folder_id = $%d AND %s", len(args), designAccess("$1", false))
+	case c.Query("workspace") == "shared":
+		where = "folder_id IN " + sharedFolders("$1", false)
+	default:
+		scope, ok := workspaceScope(c, "user_id", "team_id", "$1", &args)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace"})
+			return
+		}
+		where = scope
+		if folderID == "root" {
+			where += " AND folder_id IS NULL"
+		}
+	}
+	if c.Query("starred") == "true" {
+		where += " AND id IN (SELECT design_id FROM design_stars WHERE user_id = $1)"
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		args = append(args, containsPattern(q))
+		where += fmt.Sprintf(` AND title ILIKE $%d ESCAPE '\'`, len(args))
+	}
+	switch templateID := c.Query("template_id"); templateID {
+	case "":
+	case "none":
+		where += " AND template_id IS NULL"
+	default:
+		if !uuidPattern.MatchString(templateID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template_id"})
+			return
+		}
+		args = append(args, templateID)
+		where += fmt.Sprintf(" AND template_id = $%d", len(args))
+	}
+	for param, condition := range map[string]string{
+		"created_after":  "created_at >= $%d",
+		"created_before": "created_at < $%d",
+		"updated_after":  "updated_at >= $%d",
+		"updated_before": "updated_at < $%d",
+	} {
+		if value := c.Query(param); value != "" {
+			t, ok := parseDateParam(value)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ". Use YYYY-MM-DD or RFC 3339"})
+				return
+			}
+			args = append(args, t)
+			where += " AND " + fmt.Sprintf(condition, len(args))
+		}
+	}
+
+	direction, comparison := "ASC", ">"
+	if sort.desc {
+		direction, comparison = "DESC", "<"
+	}
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, ok := decodeCursor(cursorParam, sortName, sort.cast)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d::%s, $%d::uuid)", sort.column, comparison, len(args)-1, sort.cast, len(args))
+	}
+
+	columns := designListColumns
+	if c.Query("include") == "canvas_data" {
+		columns = designColumns
+	}
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT %s, %s, (%s)::text FROM designs WHERE %s ORDER BY %s %s, id %s LIMIT $%d`,
+		columns, designStarred("$1"), sort.column, where, sort.column, direction, direction, len(args))
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch designs"})
 		return
 	}
 	defer rows.Close()
 
-	var designs []models.Design
+	designs := []models.Design{}
+	var sortValues []string
 	for rows.Next() {
-		var design models.Design
-		var canvasData string
-		err := rows.Scan(&design.ID, &design.Title, &design.Description, &canvasData, &design.Thumbnail, &design.CreatedAt, &design.UpdatedAt)
+		var starred bool
+		var sortValue string
+		design, err := scanDesign(rows, &starred, &sortValue)
 		if err != nil {
 			continue
 		}
-		
-		// Parse canvas data
-		json.Unmarshal([]byte(canvasData), &design.CanvasData)
-		design.UserID = userID.(string)
-		designs = append(designs, design)
+		design.Starred = starred
+		designs = append(designs, *design)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	var nextCursor string
+	if len(designs) > limit {
+		designs = designs[:limit]
+		nextCursor = encodeCursor(pageCursor{Sort: sortName, Value: sortValues[limit-1], ID: designs[limit-1].ID})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"designs": designs})
+	c.JSON(http.StatusOK, gin.H{
+		"designs":     designs,
+		"next_cursor": nextCursor,
+	})
+}
+
+// parseDateParam accepts a date (taken as midnight UTC) or an RFC 3339
+// timestamp.
+func parseDateParam(value string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
 }
 
 func (h *SimpleDesignHandler) CreateDesign(c *gin.Context) {
@@ -64,6 +205,20 @@ func (h *SimpleDesignHandler) CreateDesign(c *gin.Context) {
 		return
 	}
 
+	if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+	if req.FolderID != "" {
+		var teamID *string
+		if req.TeamID != "" {
+			teamID = &req.TeamID
+		}
+		if !h.folderInWorkspace(req.FolderID, teamID, userID.(string)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Folder not found"})
+			return
+		}
+	}
+
 	// Convert canvas data to JSON
 	canvasDataJSON, err := json.Marshal(req.CanvasData)
 	if err != nil {
@@ -71,47 +226,127 @@ func (h *SimpleDesignHandler) CreateDesign(c *gin.Context) {
 		return
 	}
 
-	query := `INSERT INTO designs (title, description, canvas_data, user_id) VALUES ($1, $2, $3, $4) RETURNING id, title, description, created_at, updated_at`
-	var design models.Design
-	err = h.db.QueryRow(query, req.Title, req.Description, string(canvasDataJSON), userID).Scan(
-		&design.ID, &design.Title, &design.Description, &design.CreatedAt, &design.UpdatedAt,
-	)
+	// Start from the template's canvas unless the client sent its own
+	if req.TemplateID != nil {
+		_, templateCanvas, err := h.loadTemplate(*req.TemplateID, userID.(string))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		if req.CanvasData == nil {
+			canvasDataJSON = []byte(templateCanvas)
+		}
+	}
+
+	design, err := h.insertDesign(userID.(string), req.TeamID, req.FolderID, req.Title, req.Description, string(canvasDataJSON), req.TemplateID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create design"})
 		return
 	}
 
-	design.UserID = userID.(string)
-	design.CanvasData = req.CanvasData
-
 	c.JSON(http.StatusCreated, gin.H{"design": design})
 }
 
-func (h *SimpleDesignHandler) GetDesign(c *gin.Context) {
+// UseTemplate creates a new design owned by the caller from a template.
+func (h *SimpleDesignHandler) UseTemplate(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	designID := c.Param("id")
-	query := `SELECT id, title, description, canvas_data, thumbnail, created_at, updated_at FROM designs WHERE id = $1 AND user_id = $2`
+	var req models.UseTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+
+	templateID := c.Param("id")
+	templateTitle, templateCanvas, err := h.loadTemplate(templateID, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	title := req.Title
+	if title == "" {
+		title = templateTitle
+	}
+
+	design, err := h.insertDesign(userID.(string), req.TeamID, "", title, req.Description, templateCanvas, &templateID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create design"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"design": design})
+}
+
+// loadTemplate returns the title and raw canvas_data of a template the user
+// has access to.
+func (h *SimpleDesignHandler) loadTemplate(templateID, userID string) (string, string, error) {
+	args := []interface{}{templateID}
+	query := `SELECT title, COALESCE(canvas_data::text, '{}') FROM templates WHERE id = $1 AND ` + templateAccessClause(userID, &args)
+	var title, canvasData string
+	err := h.db.QueryRow(query, args...).Scan(&title, &canvasData)
+	return title, canvasData, err
+}
+
+// insertDesign creates a design, in a team's workspace when teamID is set,
+// and, when it was started from a template, bumps the template's usage
+// count in the same transaction.
+func (h *SimpleDesignHandler) insertDesign(userID, teamID, folderID, title, description, canvasData string, templateID *string) (*models.Design, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO designs (title, description, canvas_data, canvas_text, asset_refs, user_id, team_id, folder_id, template_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, title, description, template_id, team_id, folder_id, created_at, updated_at`
 	var design models.Design
-	var canvasData string
-	err := h.db.QueryRow(query, designID, userID).Scan(
-		&design.ID, &design.Title, &design.Description, &canvasData, &design.Thumbnail, &design.CreatedAt, &design.UpdatedAt,
+	text, refs := indexCanvas(canvasData)
+	err = tx.QueryRow(query, title, description, canvasData, text, pq.Array(refs), userID, nullableID(teamID), nullableID(folderID), templateID).Scan(
+		&design.ID, &design.Title, &design.Description, &design.TemplateID, &design.TeamID, &design.FolderID, &design.CreatedAt, &design.UpdatedAt,
 	)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
-		return
+		return nil, err
 	}
 
-	if canvasData != "" {
-		json.Unmarshal([]byte(canvasData), &design.CanvasData)
+	if err := touchRecentDesign(tx, userID, design.ID); err != nil {
+		return nil, err
+	}
+
+	if templateID != nil {
+		if _, err := tx.Exec(`UPDATE templates SET usage_count = usage_count + 1 WHERE id = $1`, *templateID); err != nil {
+			return nil, err
+		}
 	}
-	design.UserID = userID.(string)
 
-	c.JSON(http.StatusOK, gin.H{"design": design})
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	design.UserID = userID
+	json.Unmarshal([]byte(canvasData), &design.CanvasData)
+	return &design, nil
+}
+
+func (h *SimpleDesignHandler) GetDesign(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if h.respondWithDesign(c, http.StatusOK, c.Param("id"), userID.(string)) {
+		touchRecentDesign(h.db, userID.(string), c.Param("id"))
+	}
 }
 
 func (h *SimpleDesignHandler) UpdateDesign(c *gin.Context) {
@@ -128,8 +363,11 @@ func (h *SimpleDesignHandler) UpdateDesign(c *gin.Context) {
 		return
 	}
 
-	// Convert canvas data to JSON if provided
+	// Convert canvas data to JSON if provided; its search text is refreshed
+	// with it
 	var canvasDataJSON []byte
+	var text *string
+	var refs []string
 	var err error
 	if req.CanvasData != nil {
 		canvasDataJSON, err = json.Marshal(req.CanvasData)
@@ -137,24 +375,26 @@ func (h *SimpleDesignHandler) UpdateDesign(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid canvas data"})
 			return
 		}
+		var extracted string
+		extracted, refs = indexCanvas(string(canvasDataJSON))
+		text = &extracted
 	}
 
-	query := `UPDATE designs SET title = COALESCE($1, title), description = COALESCE($2, description), canvas_data = COALESCE($3, canvas_data), updated_at = NOW() WHERE id = $4 AND user_id = $5 RETURNING id, title, description, created_at, updated_at`
-	var design models.Design
-	err = h.db.QueryRow(query, req.Title, req.Description, string(canvasDataJSON), designID, userID).Scan(
-		&design.ID, &design.Title, &design.Description, &design.CreatedAt, &design.UpdatedAt,
-	)
+	// Editors of a shared folder can change the designs inside it
+	query := `UPDATE designs SET title = COALESCE($1, title), description = COALESCE($2, description), canvas_data = COALESCE(NULLIF($3, '')::jsonb, canvas_data),
+		canvas_text = COALESCE($6, canvas_text), asset_refs = COALESCE($7::text[], asset_refs), updated_at = NOW() WHERE id = $4 AND ` + designAccess("$5", true)
+	result, err := h.db.Exec(query, req.Title, req.Description, string(canvasDataJSON), designID, userID, text, pq.Array(refs))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update design"})
 		return
 	}
-
-	design.UserID = userID.(string)
-	if req.CanvasData != nil {
-		design.CanvasData = *req.CanvasData
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"design": design})
+	touchRecentDesign(h.db, userID.(string), designID)
+	h.respondWithDesign(c, http.StatusOK, designID, userID.(string))
 }
 
 func (h *SimpleDesignHandler) ExportDesign(c *gin.Context) {
@@ -172,7 +412,7 @@ func (h *SimpleDesignHandler) ExportDesign(c *gin.Context) {
 	}
 
 	// Get design from database
-	query := `SELECT canvas_data FROM designs WHERE id = $1 AND user_id = $2`
+	query := `SELECT canvas_data FROM designs WHERE id = $1 AND ` + designAccess("$2", false)
 	var canvasData string
 	err := h.db.QueryRow(query, designID, userID).Scan(&canvasData)
 	if err != nil {
@@ -197,7 +437,7 @@ func (h *SimpleDesignHandler) DeleteDesign(c *gin.Context) {
 	}
 
 	designID := c.Param("id")
-	query := `DELETE FROM designs WHERE id = $1 AND user_id = $2`
+	query := `DELETE FROM designs WHERE id = $1 AND ` + workspaceManage("user_id", "team_id", "$2")
 	result, err := h.db.Exec(query, designID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete design"})
@@ -212,3 +452,101 @@ func (h *SimpleDesignHandler) DeleteDesign(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Design deleted successfully"})
 }
+
+// TransferDesign moves a design between the caller's personal space and a
+// team, or between teams. Designs moved to a personal space become the
+// caller's own.
+func (h *SimpleDesignHandler) TransferDesign(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.TransferDesignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	teamID := stringValue(req.TeamID)
+	if !requireTeamMember(c, h.db, teamID, userID.(string)) {
+		return
+	}
+
+	// A design leaving a team becomes the mover's, so team content cannot be
+	// pushed into somebody else's personal space. Folders belong to a
+	// workspace, so a design changing workspace is unfiled.
+	query := `UPDATE designs SET team_id = $1, user_id = CASE WHEN $1::uuid IS NULL THEN $3 ELSE user_id END,
+		folder_id = CASE WHEN team_id IS NOT DISTINCT FROM $1 THEN folder_id END, updated_at = NOW()
+		WHERE id = $2 AND ` + workspaceManage("user_id", "team_id", "$3")
+	result, err := h.db.Exec(query, nullableID(teamID), c.Param("id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer design"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
+	}
+
+	audit.Record(h.db, audit.Entry{ActorID: userID.(string), Action: "design.transferred", TargetID: c.Param("id"), IPAddress: c.ClientIP(),
+		ImpersonatorID: c.GetString("impersonatorID"), Metadata: map[string]interface{}{"team_id": req.TeamID}})
+
+	h.respondWithDesign(c, http.StatusOK, c.Param("id"), userID.(string))
+}
+
+// respondWithDesign writes a design the user can open, with its folder's
+// breadcrumb path, and reports whether it was found.
+func (h *SimpleDesignHandler) respondWithDesign(c *gin.Context, status int, designID, userID string) bool {
+	var starred bool
+	query := `SELECT ` + designColumns + `, ` + designStarred("$2") + ` FROM designs WHERE id = $1 AND ` + designAccess("$2", false)
+	design, err := scanDesign(h.db.QueryRow(query, designID, userID), &starred)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return false
+	}
+	design.Starred = starred
+
+	if design.FolderID != nil {
+		if design.Path, err = folderPath(h.db, *design.FolderID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch folder path"})
+			return false
+		}
+	}
+
+	c.JSON(status, gin.H{"design": design})
+	return true
+}
+
+// indexCanvas extracts the searchable text of a serialised canvas and the
+// assets it refers to.
+func indexCanvas(canvasData string) (string, []string) {
+	var canvas interface{}
+	if err := json.Unmarshal([]byte(canvasData), &canvas); err != nil {
+		return "", []string{}
+	}
+	return fabric.ExtractText(canvas), fabric.AssetRefs(canvas)
+}
+
+// designStarred returns a column telling whether the user given by param
+// has starred the design.
+func designStarred(param string) string {
+	return `EXISTS(SELECT 1 FROM design_stars s WHERE s.design_id = designs.id AND s.user_id = ` + param + `)`
+}
+
+// scanDesign scans a row selected with designColumns followed by any extra
+// columns.
+func scanDesign(row rowScanner, extra ...interface{}) (*models.Design, error) {
+	var design models.Design
+	var canvasData string
+	dest := []interface{}{&design.ID, &design.Title, &design.Description, &canvasData, &design.Thumbnail,
+		&design.UserID, &design.TeamID, &design.FolderID, &design.TemplateID, &design.CreatedAt, &design.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if canvasData != "" {
+		json.Unmarshal([]byte(canvasData), &design.CanvasData)
+	}
+	return &design, nil
+}
@@ -2,33 +2,139 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/storage"
 )
 
+const templateColumns = `id, title, COALESCE(description, ''), category, COALESCE(thumbnail, ''), COALESCE(canvas_data::text, ''), width, height, owner_id, team_id, visibility, is_published, usage_count, created_at, updated_at`
+
+// templateSorts maps the sort query parameter to the column used for
+// ordering and cursors. Ties are broken by id in the same direction.
+var templateSorts = map[string]struct {
+	column string
+	cast   string
+	desc   bool
+}{
+	"newest":       {"created_at", "timestamp", true},
+	"popular":      {"usage_count", "integer", true},
+	"alphabetical": {"LOWER(title)", "text", false},
+	"relevance":    {"ts_rank(search_vector, websearch_to_tsquery('english', $1))::float8", "float8", true},
+}
+
 type SimpleTemplateHandler struct {
-	db *sql.DB
+	db    *sql.DB
+	store storage.Storage
 }
 
-func NewSimpleTemplateHandler(db *sql.DB) *SimpleTemplateHandler {
-	return &SimpleTemplateHandler{db: db}
+func NewSimpleTemplateHandler(db *sql.DB, store storage.Storage) *SimpleTemplateHandler {
+	return &SimpleTemplateHandler{db: db, store: store}
 }
 
 func (h *SimpleTemplateHandler) GetTemplates(c *gin.Context) {
-	category := c.Query("category")
-	
-	var query string
+	q := strings.TrimSpace(c.Query("q"))
+	limit := parseLimit(c, 50, 100)
+
+	sortName := c.DefaultQuery("sort", "newest")
+	if q != "" && c.Query("sort") == "" {
+		sortName = "relevance"
+	}
+	sort, ok := templateSorts[sortName]
+	if !ok || (sortName == "relevance" && q == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use newest, popular, alphabetical or relevance"})
+		return
+	}
+
+	// The search query is always $1 so the relevance expression can use it
 	var args []interface{}
-	
-	if category != "" {
-		query = `SELECT id, title, description, category, thumbnail, canvas_data, created_at FROM templates WHERE category = $1 ORDER BY created_at DESC`
+	if q != "" {
+		args = append(args, q)
+	}
+	userID := c.GetString("userID")
+	where := "WHERE " + templateAccessClause(userID, &args)
+	if q != "" {
+		where += " AND search_vector @@ websearch_to_tsquery('english', $1)"
+	}
+	if c.Query("owner") == "me" {
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+		args = append(args, userID)
+		where += fmt.Sprintf(" AND owner_id = $%d", len(args))
+	}
+	if category := c.Query("category"); category != "" {
 		args = append(args, category)
-	} else {
-		query = `SELECT id, title, description, category, thumbnail, canvas_data, created_at FROM templates ORDER BY created_at DESC`
+		where += fmt.Sprintf(" AND category = $%d", len(args))
+	}
+	if tags := splitList(c.Query("tags")); len(tags) > 0 {
+		args = append(args, pq.Array(tags), len(tags))
+		where += fmt.Sprintf(` AND id IN (SELECT tt.template_id FROM template_tags tt JOIN tags t ON t.id = tt.tag_id
+			WHERE t.name = ANY($%d) GROUP BY tt.template_id HAVING COUNT(DISTINCT t.name) = $%d)`, len(args)-1, len(args))
+	}
+	for param, condition := range map[string]string{
+		"min_width":  "width >= $%d",
+		"max_width":  "width <= $%d",
+		"min_height": "height >= $%d",
+		"max_height": "height <= $%d",
+	} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			args = append(args, n)
+			where += " AND " + fmt.Sprintf(condition, len(args))
+		}
+	}
+	if aspect := c.Query("aspect"); aspect != "" {
+		ratio, ok := parseAspectRatio(aspect)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid aspect. Use W:H, e.g. 16:9"})
+			return
+		}
+		args = append(args, ratio)
+		where += fmt.Sprintf(" AND height > 0 AND ABS(width::float8 / height - $%d) < 0.01", len(args))
+	}
+	switch c.Query("orientation") {
+	case "square":
+		where += " AND width = height"
+	case "landscape":
+		where += " AND width > height"
+	case "portrait":
+		where += " AND width < height"
+	}
+
+	var total int
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM templates `+where, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	direction, comparison := "ASC", ">"
+	if sort.desc {
+		direction, comparison = "DESC", "<"
+	}
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, ok := decodeCursor(cursorParam, sortName, sort.cast)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d::%s, $%d::uuid)", sort.column, comparison, len(args)-1, sort.cast, len(args))
 	}
-	
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`SELECT %s, (%s)::text FROM templates %s ORDER BY %s %s, id %s LIMIT $%d`,
+		templateColumns, sort.column, where, sort.column, direction, direction, len(args))
 	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
@@ -36,41 +142,158 @@ func (h *SimpleTemplateHandler) GetTemplates(c *gin.Context) {
 	}
 	defer rows.Close()
 
-	var templates []models.Template
+	templates := []models.Template{}
+	var sortValues []string
 	for rows.Next() {
-		var template models.Template
-		var canvasData string
-		err := rows.Scan(&template.ID, &template.Title, &template.Description, &template.Category, &template.Thumbnail, &canvasData, &template.CreatedAt)
+		var sortValue string
+		template, err := scanTemplate(rows, &sortValue)
 		if err != nil {
 			continue
 		}
-		
-		// Parse canvas data
-		if canvasData != "" {
-			template.CanvasData = &canvasData
-		}
-		templates = append(templates, template)
+		templates = append(templates, *template)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	var nextCursor string
+	if len(templates) > limit {
+		templates = templates[:limit]
+		nextCursor = encodeCursor(pageCursor{Sort: sortName, Value: sortValues[limit-1], ID: templates[limit-1].ID})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"templates": templates})
+	if err := h.attachTags(templates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates":   templates,
+		"total":       total,
+		"next_cursor": nextCursor,
+	})
 }
 
 func (h *SimpleTemplateHandler) GetTemplate(c *gin.Context) {
-	templateID := c.Param("id")
-	query := `SELECT id, title, description, category, thumbnail, canvas_data, created_at FROM templates WHERE id = $1`
-	var template models.Template
-	var canvasData string
-	err := h.db.QueryRow(query, templateID).Scan(
-		&template.ID, &template.Title, &template.Description, &template.Category, &template.Thumbnail, &canvasData, &template.CreatedAt,
-	)
+	args := []interface{}{c.Param("id")}
+	query := `SELECT ` + templateColumns + ` FROM templates WHERE id = $1 AND ` + templateAccessClause(c.GetString("userID"), &args)
+	template, err := scanTemplate(h.db.QueryRow(query, args...))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
 		return
 	}
 
+	h.respondWithTemplate(c, http.StatusOK, template)
+}
+
+func (h *SimpleTemplateHandler) GetCategories(c *gin.Context) {
+	var args []interface{}
+	query := `SELECT category, COUNT(*) FROM templates WHERE ` + templateAccessClause(c.GetString("userID"), &args) + ` GROUP BY category ORDER BY category`
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+	defer rows.Close()
+
+	categories := []models.TemplateCategory{}
+	for rows.Next() {
+		var category models.TemplateCategory
+		if err := rows.Scan(&category.Name, &category.Count); err != nil {
+			continue
+		}
+		categories = append(categories, category)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// templateAccessClause returns a WHERE condition limiting templates to those
+// userID may use: published public templates plus the private ones in the
+// user's own space and their teams. Anonymous callers pass an empty userID.
+func templateAccessClause(userID string, args *[]interface{}) string {
+	public := "(visibility = 'public' AND is_published = TRUE)"
+	if userID == "" {
+		return public
+	}
+	*args = append(*args, userID)
+	return fmt.Sprintf("(%s OR %s)", public, workspaceAccess("owner_id", "team_id", fmt.Sprintf("$%d", len(*args))))
+}
+
+// attachTags loads the tags of every template in one query.
+func (h *SimpleTemplateHandler) attachTags(templates []models.Template) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(templates))
+	index := make(map[string]int, len(templates))
+	for i := range templates {
+		ids[i] = templates[i].ID
+		index[templates[i].ID] = i
+		templates[i].Tags = []string{}
+	}
+
+	query := `SELECT tt.template_id, t.name FROM template_tags tt JOIN tags t ON t.id = tt.tag_id WHERE tt.template_id = ANY($1::uuid[]) ORDER BY t.name`
+	rows, err := h.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var templateID, tag string
+		if err := rows.Scan(&templateID, &tag); err != nil {
+			return err
+		}
+		i := index[templateID]
+		templates[i].Tags = append(templates[i].Tags, tag)
+	}
+	return rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTemplate scans a row selected with templateColumns followed by any
+// extra columns.
+func scanTemplate(row rowScanner, extra ...interface{}) (*models.Template, error) {
+	var template models.Template
+	var canvasData string
+	var width, height sql.NullInt64
+	dest := []interface{}{&template.ID, &template.Title, &template.Description, &template.Category, &template.Thumbnail,
+		&canvasData, &width, &height, &template.OwnerID, &template.TeamID, &template.Visibility, &template.IsPublished, &template.UsageCount,
+		&template.CreatedAt, &template.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
 	if canvasData != "" {
 		template.CanvasData = &canvasData
 	}
+	template.Width = int(width.Int64)
+	template.Height = int(height.Int64)
+	return &template, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(strings.ToLower(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
-	c.JSON(http.StatusOK, gin.H{"template": template})
+// parseAspectRatio parses "W:H" into W/H.
+func parseAspectRatio(value string) (float64, bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	w, err1 := strconv.ParseFloat(parts[0], 64)
+	h, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, false
+	}
+	return w / h, true
 }
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/audit"
+	"canvas-designer-backend/internal/fabric"
+	"canvas-designer-backend/internal/imaging"
+	"canvas-designer-backend/internal/models"
+)
+
+func (h *SimpleTemplateHandler) AdminGetTemplates(c *gin.Context) {
+	query := `SELECT ` + templateColumns + ` FROM templates`
+	switch c.Query("published") {
+	case "true":
+		query += ` WHERE is_published = TRUE`
+	case "false":
+		query += ` WHERE is_published = FALSE`
+	}
+	query += ` ORDER BY updated_at DESC`
+
+	rows, err := h.db.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+	defer rows.Close()
+
+	templates := []models.Template{}
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, *template)
+	}
+
+	if err := h.attachTags(templates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func (h *SimpleTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req models.CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := fabric.Validate(req.CanvasData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	canvasDataJSON, err := json.Marshal(req.CanvasData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid canvas data"})
+		return
+	}
+
+	query := `INSERT INTO templates (title, description, category, canvas_data, is_published) VALUES ($1, $2, $3, $4, $5) RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, req.Title, req.Description, req.Category, string(canvasDataJSON), req.IsPublished))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if err := h.setTags(template.ID, req.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template tags"})
+		return
+	}
+
+	h.auditTemplate(c, "template.created", template.ID)
+	h.respondWithTemplate(c, http.StatusCreated, template)
+}
+
+func (h *SimpleTemplateHandler) UpdateTemplate(c *gin.Context) {
+	h.updateTemplate(c, "")
+}
+
+// updateTemplate applies a partial update. A non-empty ownerID restricts the
+// update to templates owned by that user.
+func (h *SimpleTemplateHandler) updateTemplate(c *gin.Context, ownerID string) {
+	templateID := c.Param("id")
+	var req models.UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var canvasDataJSON *string
+	if req.CanvasData != nil {
+		if err := fabric.Validate(*req.CanvasData); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		data, err := json.Marshal(*req.CanvasData)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid canvas data"})
+			return
+		}
+		encoded := string(data)
+		canvasDataJSON = &encoded
+	}
+
+	query := `UPDATE templates SET title = COALESCE($1, title), description = COALESCE($2, description), category = COALESCE($3, category),
+		canvas_data = COALESCE($4::jsonb, canvas_data) WHERE id = $5 AND ($6 = '' OR ` + workspaceManage("owner_id", "team_id", "NULLIF($6, '')::uuid") + `) RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, req.Title, req.Description, req.Category, canvasDataJSON, templateID, ownerID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if req.Tags != nil {
+		if err := h.setTags(template.ID, *req.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template tags"})
+			return
+		}
+	}
+
+	h.auditTemplate(c, "template.updated", template.ID)
+	h.respondWithTemplate(c, http.StatusOK, template)
+}
+
+func (h *SimpleTemplateHandler) DeleteTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	result, err := h.db.Exec(`DELETE FROM templates WHERE id = $1`, templateID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	h.auditTemplate(c, "template.deleted", templateID)
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+func (h *SimpleTemplateHandler) PublishTemplate(c *gin.Context) {
+	h.setPublished(c, true)
+}
+
+func (h *SimpleTemplateHandler) UnpublishTemplate(c *gin.Context) {
+	h.setPublished(c, false)
+}
+
+// UploadTemplateThumbnail stores an image as the template's thumbnail.
+// Thumbnails are served publicly from /uploads, so the file's type is taken
+// from its content, never from the client, and its metadata is stripped.
+// The replaced thumbnail is deleted unless something else still uses it.
+func (h *SimpleTemplateHandler) UploadTemplateThumbnail(c *gin.Context) {
+	templateID := c.Param("id")
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if file.Size > 10*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum size is 10MB"})
+		return
+	}
+
+	var previous string
+	if err := h.db.QueryRow(`SELECT COALESCE(thumbnail, '') FROM templates WHERE id = $1`, templateID).Scan(&previous); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, 10*1024*1024))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	format, ok := imaging.Sniff(data)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only JPG, PNG, GIF and WebP are allowed"})
+		return
+	}
+	if _, err := imaging.Decode(data, format); err != nil {
+		if err == imaging.ErrTooLarge {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions are too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+		return
+	}
+	if data, err = imaging.StripMetadata(data, format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+		return
+	}
+
+	filename := fmt.Sprintf("template-%s-%d%s", templateID, time.Now().Unix(), format.Ext())
+	if err := h.store.Put(c.Request.Context(), uploadsPrefix+filename, bytes.NewReader(data), int64(len(data)), format.MimeType()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	query := `UPDATE templates SET thumbnail = $1 WHERE id = $2 RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, "/uploads/"+filename, templateID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	if previous != "" && previous != template.Thumbnail {
+		h.deleteThumbnail(c.Request.Context(), previous)
+	}
+
+	h.auditTemplate(c, "template.thumbnail_updated", template.ID)
+	h.respondWithTemplate(c, http.StatusOK, template)
+}
+
+// deleteThumbnail removes a thumbnail uploaded through
+// UploadTemplateThumbnail once no template or design refers to it. Other
+// thumbnails, such as those copied from a design, are left alone.
+func (h *SimpleTemplateHandler) deleteThumbnail(ctx context.Context, thumbnail string) {
+	name := strings.TrimPrefix(thumbnail, "/uploads/")
+	if name == thumbnail || !strings.HasPrefix(name, "template-") || strings.Contains(name, "/") {
+		return
+	}
+
+	var used bool
+	query := `SELECT EXISTS(SELECT 1 FROM templates WHERE thumbnail = $1) OR EXISTS(SELECT 1 FROM designs WHERE thumbnail = $1)`
+	if err := h.db.QueryRow(query, thumbnail).Scan(&used); err != nil {
+		log.Printf("Failed to check references to %s: %v", thumbnail, err)
+		return
+	}
+	if used {
+		return
+	}
+	if err := h.store.Delete(ctx, uploadsPrefix+name); err != nil {
+		log.Printf("Failed to delete %s: %v", thumbnail, err)
+	}
+}
+
+// PromoteDesign copies an existing design's canvas_data into a new template.
+func (h *SimpleTemplateHandler) PromoteDesign(c *gin.Context) {
+	designID := c.Param("id")
+	var req models.PromoteDesignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var title, description, thumbnail, canvasData string
+	query := `SELECT title, COALESCE(description, ''), COALESCE(thumbnail, ''), COALESCE(canvas_data::text, '') FROM designs WHERE id = $1`
+	if err := h.db.QueryRow(query, designID).Scan(&title, &description, &thumbnail, &canvasData); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(canvasData), &parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Design has no valid canvas data"})
+		return
+	}
+	if err := fabric.Validate(parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title != "" {
+		title = req.Title
+	}
+	if req.Description != "" {
+		description = req.Description
+	}
+
+	query = `INSERT INTO templates (title, description, category, thumbnail, canvas_data, is_published) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6) RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, title, description, req.Category, thumbnail, canvasData, req.IsPublished))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if err := h.setTags(template.ID, req.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template tags"})
+		return
+	}
+
+	h.auditTemplate(c, "template.promoted", template.ID)
+	h.respondWithTemplate(c, http.StatusCreated, template)
+}
+
+func (h *SimpleTemplateHandler) setPublished(c *gin.Context, published bool) {
+	templateID := c.Param("id")
+	query := `UPDATE templates SET is_published = $1 WHERE id = $2 RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, published, templateID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	action := "template.unpublished"
+	if published {
+		action = "template.published"
+	}
+	h.auditTemplate(c, action, template.ID)
+	h.respondWithTemplate(c, http.StatusOK, template)
+}
+
+func (h *SimpleTemplateHandler) auditTemplate(c *gin.Context, action, templateID string) {
+	audit.Record(h.db, audit.Entry{ActorID: c.GetString("userID"), Action: action, TargetID: templateID, IPAddress: c.ClientIP()})
+}
+
+// setTags replaces a template's tags, creating any tags that do not exist.
+func (h *SimpleTemplateHandler) setTags(templateID string, tags []string) error {
+	return replaceTags(h.db, "template_tags", "template_id", templateID, tags)
+}
+
+// replaceTags replaces the rows of a tag join table (e.g. template_tags)
+// for one item, creating any tags that do not exist.
+func replaceTags(db *sql.DB, table, column, id string, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, table, column), id); err != nil {
+		return err
+	}
+	for _, tag := range splitList(strings.Join(tags, ",")) {
+		var tagID string
+		query := `INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id`
+		if err := tx.QueryRow(query, tag).Scan(&tagID); err != nil {
+			return err
+		}
+		query = fmt.Sprintf(`INSERT INTO %s (%s, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, table, column)
+		if _, err := tx.Exec(query, id, tagID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (h *SimpleTemplateHandler) respondWithTemplate(c *gin.Context, status int, template *models.Template) {
+	templates := []models.Template{*template}
+	if err := h.attachTags(templates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template tags"})
+		return
+	}
+	c.JSON(status, gin.H{"template": templates[0]})
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/fabric"
+	"canvas-designer-backend/internal/middleware"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/render"
+	"canvas-designer-backend/internal/storage"
+)
+
+const maxGenerationRecords = 1000
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+type TemplateGenerationHandler struct {
+	db    *sql.DB
+	store storage.Storage
+}
+
+func NewTemplateGenerationHandler(db *sql.DB, store storage.Storage) *TemplateGenerationHandler {
+	return &TemplateGenerationHandler{db: db, store: store}
+}
+
+func (h *TemplateGenerationHandler) GetTemplateVariables(c *gin.Context) {
+	templateID := c.Param("id")
+	_, canvas, err := h.loadTemplateCanvas(templateID, c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"variables": fabric.ExtractVariables(canvas)})
+}
+
+// GenerateDesigns queues a background job that creates one design per
+// record. Records come either as JSON or as a CSV file whose header row
+// names the variables.
+func (h *TemplateGenerationHandler) GenerateDesigns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.GenerateDesignsRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		records, err := parseCSVRecords(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Records = records
+		req.Title = c.PostForm("title")
+		req.Export = c.PostForm("export")
+		if req.Export != "" && req.Export != "svg" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format. Use svg"})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one record is required"})
+		return
+	}
+	if len(req.Records) > maxGenerationRecords {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many records. Maximum is %d", maxGenerationRecords)})
+		return
+	}
+	if req.Export != "" && !middleware.HasScope(c, models.ScopeExport) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing required scope: " + models.ScopeExport})
+		return
+	}
+
+	templateID := c.Param("id")
+	templateTitle, canvas, err := h.loadTemplateCanvas(templateID, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	if req.Title == "" {
+		req.Title = templateTitle + " {{_row}}"
+	}
+
+	query := `INSERT INTO generation_jobs (user_id, template_id, status, total, export) VALUES ($1, $2, 'queued', $3, $4) RETURNING id, created_at`
+	job := models.GenerationJob{TemplateID: templateID, Status: "queued", Total: len(req.Records), Export: req.Export, DesignIDs: []string{}}
+	if err := h.db.QueryRow(query, userID, templateID, job.Total, req.Export).Scan(&job.ID, &job.CreatedAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create generation job"})
+		return
+	}
+
+	go h.runJob(job.ID, userID.(string), templateID, canvas, req)
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (h *TemplateGenerationHandler) GetJob(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	job, err := h.findJob(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+func (h *TemplateGenerationHandler) DownloadJob(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	job, err := h.findJob(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if job.Export == "" || job.Status != "completed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has no renders to download"})
+		return
+	}
+
+	obj, err := h.store.Get(c.Request.Context(), jobArchiveKey(job.ID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job archive not found"})
+		return
+	}
+	defer obj.Body.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, "application/zip", obj.Body, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="designs-%s.zip"`, job.ID),
+	})
+}
+
+func (h *TemplateGenerationHandler) runJob(jobID, userID, templateID string, canvas interface{}, req models.GenerateDesignsRequest) {
+	// A panic would otherwise take the server down and leave the job running
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Generation job %s panicked: %v\n%s", jobID, r, debug.Stack())
+			h.failJob(jobID, "internal error")
+		}
+	}()
+
+	h.db.Exec(`UPDATE generation_jobs SET status = 'running' WHERE id = $1`, jobID)
+
+	// The archive is built in a temporary file and then handed to storage
+	var archiveFile *os.File
+	var archive *zip.Writer
+	if req.Export != "" {
+		f, err := os.CreateTemp("", "generation-*.zip")
+		if err != nil {
+			h.failJob(jobID, "failed to create archive")
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		archiveFile = f
+		archive = zip.NewWriter(f)
+	}
+
+	// Text is rendered with the owner's uploaded fonts, as in the editor
+	var faces []render.FontFace
+	if archive != nil {
+		var err error
+		if faces, err = loadFontFaces(context.Background(), h.db, h.store, userID, render.FontFamilies(canvas)); err != nil {
+			log.Printf("Failed to load fonts for job %s: %v", jobID, err)
+		}
+	}
+
+	var designIDs []string
+	for i, record := range req.Records {
+		values := map[string]string{"_row": strconv.Itoa(i + 1)}
+		for key, value := range record {
+			values[key] = value
+		}
+
+		generated := fabric.ApplyVariables(canvas, values)
+		canvasJSON, err := json.Marshal(generated)
+		if err != nil {
+			h.failJob(jobID, fmt.Sprintf("record %d: invalid canvas data", i+1))
+			return
+		}
+
+		title := fabric.Substitute(req.Title, values)
+		var designID string
+		query := `INSERT INTO designs (title, canvas_data, canvas_text, asset_refs, user_id, template_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		if err := h.db.QueryRow(query, title, string(canvasJSON), fabric.ExtractText(generated), pq.Array(fabric.AssetRefs(generated)), userID, templateID).Scan(&designID); err != nil {
+			h.failJob(jobID, fmt.Sprintf("record %d: failed to create design", i+1))
+			return
+		}
+		designIDs = append(designIDs, designID)
+
+		if archive != nil {
+			svg, err := render.SVG(generated, faces...)
+			if err == nil {
+				err = writeZipBytes(archive, fmt.Sprintf("%04d-%s.svg", i+1, slugify(title)), svg)
+			}
+			if err != nil {
+				h.failJob(jobID, fmt.Sprintf("record %d: failed to render design", i+1))
+				return
+			}
+		}
+
+		h.db.Exec(`UPDATE generation_jobs SET completed = $1, design_ids = $2 WHERE id = $3`, i+1, pq.Array(designIDs), jobID)
+	}
+
+	if archive != nil {
+		if err := h.storeArchive(jobID, archive, archiveFile); err != nil {
+			log.Printf("Failed to store archive for job %s: %v", jobID, err)
+			h.failJob(jobID, "failed to write archive")
+			return
+		}
+	}
+
+	// A bulk run counts as a single use of the template
+	h.db.Exec(`UPDATE templates SET usage_count = usage_count + 1 WHERE id = $1`, templateID)
+	h.db.Exec(`UPDATE generation_jobs SET status = 'completed', finished_at = NOW() WHERE id = $1`, jobID)
+}
+
+func (h *TemplateGenerationHandler) storeArchive(jobID string, archive *zip.Writer, f *os.File) error {
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return h.store.Put(context.Background(), jobArchiveKey(jobID), f, size, "application/zip")
+}
+
+func (h *TemplateGenerationHandler) failJob(jobID, message string) {
+	log.Printf("Generation job %s failed: %s", jobID, message)
+	h.db.Exec(`UPDATE generation_jobs SET status = 'failed', error = $1, finished_at = NOW() WHERE id = $2`, message, jobID)
+}
+
+// FailInterruptedJobs marks jobs left queued or running by a previous
+// server process as failed. Jobs run in the process that created them, so
+// none of these can still finish; call it once at startup.
+func (h *TemplateGenerationHandler) FailInterruptedJobs() {
+	result, err := h.db.Exec(`UPDATE generation_jobs SET status = 'failed', error = 'interrupted by a server restart', finished_at = NOW()
+		WHERE status IN ('queued', 'running')`)
+	if err != nil {
+		log.Printf("Failed to fail interrupted generation jobs: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Marked %d interrupted generation jobs as failed", n)
+	}
+}
+
+func (h *TemplateGenerationHandler) findJob(jobID, userID string) (*models.GenerationJob, error) {
+	query := `SELECT id, template_id, status, total, completed, COALESCE(export, ''), design_ids, COALESCE(error, ''), created_at, finished_at
+		FROM generation_jobs WHERE id = $1 AND user_id = $2`
+	var job models.GenerationJob
+	err := h.db.QueryRow(query, jobID, userID).Scan(&job.ID, &job.TemplateID, &job.Status, &job.Total, &job.Completed,
+		&job.Export, pq.Array(&job.DesignIDs), &job.Error, &job.CreatedAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if job.DesignIDs == nil {
+		job.DesignIDs = []string{}
+	}
+	if job.Export != "" && job.Status == "completed" {
+		job.DownloadURL = fmt.Sprintf("/api/generation-jobs/%s/download", job.ID)
+	}
+	return &job, nil
+}
+
+func (h *TemplateGenerationHandler) loadTemplateCanvas(templateID, userID string) (string, interface{}, error) {
+	args := []interface{}{templateID}
+	query := `SELECT title, COALESCE(canvas_data::text, '{}') FROM templates WHERE id = $1 AND ` + templateAccessClause(userID, &args)
+	var title, canvasData string
+	if err := h.db.QueryRow(query, args...).Scan(&title, &canvasData); err != nil {
+		return "", nil, err
+	}
+
+	var canvas interface{}
+	if err := json.Unmarshal([]byte(canvasData), &canvas); err != nil {
+		return "", nil, err
+	}
+	return title, canvas, nil
+}
+
+// parseCSVRecords reads the "records" CSV upload; the header row supplies
+// the variable names.
+func parseCSVRecords(c *gin.Context) ([]map[string]string, error) {
+	file, err := c.FormFile("records")
+	if err != nil {
+		return nil, fmt.Errorf("no records file uploaded")
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read records file")
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("records file must start with a header row")
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				record[strings.TrimSpace(name)] = row[i]
+			}
+		}
+		records = append(records, record)
+		if len(records) > maxGenerationRecords {
+			break
+		}
+	}
+	return records, nil
+}
+
+func jobArchiveKey(jobID string) string {
+	return exportsPrefix + "jobs/" + jobID + ".zip"
+}
+
+func slugify(s string) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	if slug == "" {
+		slug = "design"
+	}
+	return slug
+}
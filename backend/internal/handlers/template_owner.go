@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/fabric"
+	"canvas-designer-backend/internal/models"
+)
+
+// SaveDesignAsTemplate copies a design the user can access into a private
+// template owned by them, in the design's team when it belongs to one.
+func (h *SimpleTemplateHandler) SaveDesignAsTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.SaveAsTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var title, description, thumbnail, canvasData string
+	var teamID *string
+	query := `SELECT title, COALESCE(description, ''), COALESCE(thumbnail, ''), COALESCE(canvas_data::text, ''), team_id FROM designs
+		WHERE id = $1 AND ` + workspaceAccess("user_id", "team_id", "$2")
+	if err := h.db.QueryRow(query, c.Param("id"), userID).Scan(&title, &description, &thumbnail, &canvasData, &teamID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Design not found"})
+		return
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(canvasData), &parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Design has no valid canvas data"})
+		return
+	}
+	if err := fabric.Validate(parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Title != "" {
+		title = req.Title
+	}
+	if req.Description != "" {
+		description = req.Description
+	}
+
+	query = `INSERT INTO templates (title, description, category, thumbnail, canvas_data, owner_id, team_id, visibility, is_published)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, 'private', FALSE) RETURNING ` + templateColumns
+	template, err := scanTemplate(h.db.QueryRow(query, title, description, req.Category, thumbnail, canvasData, userID, teamID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if err := h.setTags(template.ID, req.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template tags"})
+		return
+	}
+
+	h.respondWithTemplate(c, http.StatusCreated, template)
+}
+
+func (h *SimpleTemplateHandler) UpdateOwnTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	h.updateTemplate(c, userID.(string))
+}
+
+func (h *SimpleTemplateHandler) DeleteOwnTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM templates WHERE id = $1 AND `+workspaceManage("owner_id", "team_id", "$2"), c.Param("id"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
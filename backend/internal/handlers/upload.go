@@ -1,31 +1,95 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"image"
+	"io"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"canvas-designer-backend/internal/imaging"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/remote"
+	"canvas-designer-backend/internal/scanner"
+	"canvas-designer-backend/internal/storage"
+	"canvas-designer-backend/internal/svg"
 )
 
-type UploadHandler struct{}
+// Storage key prefixes; uploads are served from /uploads/<name>. Originals
+// wait under quarantine/, which is never served, until they are scanned.
+const (
+	uploadsPrefix    = "uploads/"
+	exportsPrefix    = "exports/"
+	quarantinePrefix = "quarantine/"
+)
+
+const assetColumns = `id, owner_id, original_name, mime_type, size, width, height, hash, storage_key, visibility, team_id, folder_id, scan_status, scan_signature, keep_original, created_at`
 
-func NewUploadHandler() *UploadHandler {
-	return &UploadHandler{}
+// uploadVariants are the renditions stored for each upload, smallest first.
+var uploadVariants = []struct {
+	name string
+	size int
+}{
+	{"thumb", 256},
+	{"medium", 1024},
+	{"full", imaging.MaxDimension},
 }
 
+type UploadHandler struct {
+	db           *sql.DB
+	store        storage.Storage
+	scanner      scanner.Scanner
+	defaultQuota int64
+	// maxUploadSize limits resumable uploads; single requests stay at 10MB
+	maxUploadSize int64
+	fetcher       *remote.Fetcher
+}
+
+func NewUploadHandler(db *sql.DB, store storage.Storage, scanner scanner.Scanner, fetcher *remote.Fetcher, defaultQuota, maxUploadSize int64) *UploadHandler {
+	return &UploadHandler{db: db, store: store, scanner: scanner, fetcher: fetcher, defaultQuota: defaultQuota, maxUploadSize: maxUploadSize}
+}
+
+// UploadImage records an image as a quarantined asset owned by the user, or
+// in a team's workspace when the team_id form field is set, and scans it.
+// Once cleared, its metadata is stripped, its dimensions capped and thumb,
+// medium and full variants are stored under the SHA-256 hash of the
+// original, so identical uploads share objects. Files the scanner flags are
+// rejected; files that cannot be scanned yet stay quarantined and are
+// retried by RescanQuarantined.
 func (h *UploadHandler) UploadImage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	file, err := c.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
 		return
 	}
 
-	// Validate file type
-	if !isValidImageType(file.Header.Get("Content-Type")) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only JPG, PNG, and GIF are allowed"})
+	// Uploads are private unless explicitly shared
+	visibility := c.DefaultPostForm("visibility", "private")
+	if visibility != "private" && visibility != "public" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Visibility must be public or private"})
+		return
+	}
+
+	teamID := c.PostForm("team_id")
+	if teamID != "" && !uuidPattern.MatchString(teamID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team_id"})
+		return
+	}
+	if !requireTeamMember(c, h.db, teamID, userID.(string)) {
 		return
 	}
 
@@ -35,46 +99,437 @@ func (h *UploadHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadsDir := "uploads"
-	if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create uploads directory"})
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d%s", file.Size, ext)
-	filepath := filepath.Join(uploadsDir, filename)
+	h.ingest(c, userID.(string), teamID, file.Filename, visibility, data, false)
+}
+
+// ingest records and scans a received file; it is shared by single and
+// resumable uploads. Storage counts towards the uploader's quota even when
+// the asset belongs to a team. With keepOriginal, larger images are
+// accepted and the file itself is stored alongside the capped variants. It
+// reports whether the asset was recorded; otherwise an error response has
+// been written.
+func (h *UploadHandler) ingest(c *gin.Context, userID, teamID, filename, visibility string, data []byte, keepOriginal bool) bool {
+	// Validate file type from its magic bytes rather than the client's header.
+	// SVGs are rebuilt from safe elements and stored without rasterising.
+	format, ok := imaging.Sniff(data)
+	isSVG := !ok && svg.Detect(data)
+	if !ok && !isSVG {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only JPG, PNG, GIF, WebP and SVG are allowed"})
+		return false
+	}
+	var svgInfo *svg.Info
+	if isSVG {
+		var err error
+		if data, svgInfo, err = svg.Sanitize(data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SVG file: " + err.Error()})
+			return false
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	// Uploading the same file twice returns the existing asset
+	query := `SELECT ` + assetColumns + ` FROM assets WHERE owner_id = $1 AND team_id IS NOT DISTINCT FROM $2 AND hash = $3`
+	if asset, err := scanAsset(h.db.QueryRow(query, userID, nullableID(teamID), hash)); err == nil {
+		h.respondWithAsset(c, asset)
+		return true
+	}
 
-	// Save file
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
+	usage, err := storageUsage(h.db, userID, h.defaultQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return false
+	}
+	if usage.UsedBytes+int64(len(data)) > usage.QuotaBytes {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Storage quota exceeded", "usage": usage})
+		return false
+	}
+
+	var img image.Image
+	var mimeType, ext string
+	var width, height int
+	if isSVG {
+		mimeType, ext = svg.MimeType, ".svg"
+		width, height = int(svgInfo.Width), int(svgInfo.Height)
+	} else {
+		var err error
+		img, err = decodeUpload(data, format, keepOriginal)
+		if err != nil {
+			if err == imaging.ErrTooLarge {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions are too large"})
+				return false
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image file"})
+			return false
+		}
+		mimeType, ext = format.MimeType(), format.Ext()
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	key := quarantineKey(userID, teamID, hash, ext)
+	if err := h.store.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), mimeType); err != nil {
+		log.Printf("Failed to quarantine upload: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return false
+	}
+
+	asset, err := h.insertAsset(userID, teamID, filename, hash, key, visibility, mimeType, len(data), width, height, keepOriginal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record upload"})
+		return false
+	}
+
+	if asset.ScanStatus == "pending" {
+		cleared, err := h.clearAsset(c.Request.Context(), asset, key, data, img)
+		if err != nil {
+			log.Printf("Asset %s stays quarantined: %v", asset.ID, err)
+		} else {
+			asset = cleared
+		}
+	}
+
+	h.respondWithAsset(c, asset)
+	return true
+}
+
+// quarantineKey is where an upload waits to be scanned. Assets are unique
+// per owner, workspace and hash, and so are their keys: another owner's
+// pending copy of the same file is never deleted when this one clears.
+func quarantineKey(userID, teamID, hash, ext string) string {
+	workspace := teamID
+	if workspace == "" {
+		workspace = "personal"
+	}
+	return fmt.Sprintf("%s%s/%s/%s%s", quarantinePrefix, userID, workspace, hash, ext)
+}
+
+// decodeUpload decodes an uploaded raster image, allowing more pixels when
+// its original is kept.
+func decodeUpload(data []byte, format imaging.Format, keepOriginal bool) (image.Image, error) {
+	if keepOriginal {
+		return imaging.DecodeLimit(data, format, imaging.MaxOriginalPixels)
+	}
+	return imaging.Decode(data, format)
+}
+
+// RescanQuarantined retries scanning assets that are still quarantined.
+func (h *UploadHandler) RescanQuarantined() {
+	rows, err := h.db.Query(`SELECT ` + assetColumns + ` FROM assets WHERE scan_status = 'pending' ORDER BY created_at LIMIT 100`)
+	if err != nil {
+		log.Printf("Failed to fetch quarantined assets: %v", err)
+		return
+	}
+	var assets []*models.Asset
+	for rows.Next() {
+		if asset, err := scanAsset(rows); err == nil {
+			assets = append(assets, asset)
+		}
+	}
+	rows.Close()
+
+	ctx := context.Background()
+	for _, asset := range assets {
+		key := strings.TrimPrefix(asset.URL, "/")
+		data, err := h.readObject(ctx, key)
+		if err != nil {
+			log.Printf("Failed to read quarantined asset %s: %v", asset.ID, err)
+			continue
+		}
+		if _, err := h.clearAsset(ctx, asset, key, data, nil); err != nil {
+			log.Printf("Asset %s stays quarantined: %v", asset.ID, err)
+		}
+	}
+}
+
+// clearAsset scans a quarantined asset. Flagged assets are marked rejected
+// and their original is kept in quarantine; clean ones are published. An
+// error leaves the asset quarantined. img is the decoded raster image, or
+// nil to decode data here.
+func (h *UploadHandler) clearAsset(ctx context.Context, asset *models.Asset, key string, data []byte, img image.Image) (*models.Asset, error) {
+	result, err := h.scanner.Scan(ctx, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Infected {
+		query := `UPDATE assets SET scan_status = 'rejected', scan_signature = $1 WHERE id = $2 RETURNING ` + assetColumns
+		log.Printf("Asset %s rejected by scanner: %s", asset.ID, result.Signature)
+		return scanAsset(h.db.QueryRow(query, result.Signature, asset.ID))
+	}
+
+	var variants []storedVariant
+	if asset.MimeType == svg.MimeType {
+		variants, err = h.storeSVG(ctx, asset, data)
+	} else {
+		format, ok := imaging.Sniff(data)
+		if !ok {
+			return nil, imaging.ErrUnsupportedFormat
+		}
+		if img == nil {
+			if img, err = decodeUpload(data, format, asset.KeepOriginal); err != nil {
+				return nil, err
+			}
+		}
+		variants, err = h.storeVariants(ctx, asset.Hash, format, img)
+		if err == nil && asset.KeepOriginal {
+			var original *storedVariant
+			if original, err = h.storeOriginal(ctx, asset.Hash, format, data, img); err == nil {
+				variants = append(variants, *original)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	published, err := h.publishAsset(asset.ID, variants)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(key, quarantinePrefix) {
+		if err := h.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+	return published, nil
+}
+
+func (h *UploadHandler) readObject(ctx context.Context, key string) ([]byte, error) {
+	obj, err := h.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	return io.ReadAll(obj.Body)
+}
+
+// storedVariant is an image variant written to storage.
+type storedVariant struct {
+	models.AssetVariant
+	key      string
+	mimeType string
+}
+
+// storeOriginal writes the uploaded file itself, with its metadata
+// stripped, as the "original" variant.
+func (h *UploadHandler) storeOriginal(ctx context.Context, hash string, format imaging.Format, data []byte, img image.Image) (*storedVariant, error) {
+	stripped, err := imaging.StripMetadata(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s%s-original%s", uploadsPrefix, hash, format.Ext())
+	size := int64(len(stripped))
+	if err := h.store.Put(ctx, key, bytes.NewReader(stripped), size, format.MimeType()); err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	return &storedVariant{
+		AssetVariant: models.AssetVariant{Name: "original", Format: string(format), Width: b.Dx(), Height: b.Dy(), Size: size, URL: "/" + key},
+		key:          key,
+		mimeType:     format.MimeType(),
+	}, nil
+}
+
+// storeVariants writes every upload variant in the primary format (JPEG for
+// JPEG sources, PNG otherwise) and in WebP. The first variant returned is
+// the full-size image in the primary format.
+func (h *UploadHandler) storeVariants(ctx context.Context, hash string, source imaging.Format, img image.Image) ([]storedVariant, error) {
+	primary := imaging.PNG
+	if source == imaging.JPEG {
+		primary = imaging.JPEG
+	}
+
+	var variants []storedVariant
+	for i := len(uploadVariants) - 1; i >= 0; i-- {
+		spec := uploadVariants[i]
+		resized := imaging.Fit(img, spec.size, spec.size)
+		for _, format := range []imaging.Format{primary, imaging.WebP} {
+			var buf bytes.Buffer
+			if err := imaging.Encode(&buf, resized, format, 85); err != nil {
+				return nil, err
+			}
+
+			key := fmt.Sprintf("%s%s-%s%s", uploadsPrefix, hash, spec.name, format.Ext())
+			size := int64(buf.Len())
+			if err := h.store.Put(ctx, key, &buf, size, format.MimeType()); err != nil {
+				return nil, err
+			}
+
+			b := resized.Bounds()
+			variants = append(variants, storedVariant{
+				AssetVariant: models.AssetVariant{Name: spec.name, Format: string(format), Width: b.Dx(), Height: b.Dy(), Size: size, URL: "/" + key},
+				key:          key,
+				mimeType:     format.MimeType(),
+			})
+		}
+	}
+	return variants, nil
+}
+
+// storeSVG publishes a sanitised SVG as its only variant; it scales without
+// resized copies.
+func (h *UploadHandler) storeSVG(ctx context.Context, asset *models.Asset, data []byte) ([]storedVariant, error) {
+	key := fmt.Sprintf("%s%s-full.svg", uploadsPrefix, asset.Hash)
+	if err := h.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), svg.MimeType); err != nil {
+		return nil, err
+	}
+
+	variant := storedVariant{
+		AssetVariant: models.AssetVariant{Name: "full", Format: "svg", Size: int64(len(data)), URL: "/" + key},
+		key:          key,
+		mimeType:     svg.MimeType,
+	}
+	if asset.Width != nil && asset.Height != nil {
+		variant.Width, variant.Height = *asset.Width, *asset.Height
+	}
+	return []storedVariant{variant}, nil
+}
+
+// insertAsset records a quarantined upload.
+func (h *UploadHandler) insertAsset(userID, teamID, name, hash, key, visibility, mimeType string, size, width, height int, keepOriginal bool) (*models.Asset, error) {
+	query := `INSERT INTO assets (owner_id, team_id, original_name, mime_type, size, width, height, hash, storage_key, visibility, keep_original)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (owner_id, team_id, hash) DO UPDATE SET original_name = assets.original_name
+		RETURNING ` + assetColumns
+	return scanAsset(h.db.QueryRow(query, userID, nullableID(teamID), name, mimeType, size, width, height, hash, key, visibility, keepOriginal))
+}
+
+// publishAsset marks an asset clean and points it at its stored variants.
+func (h *UploadHandler) publishAsset(assetID string, variants []storedVariant) (*models.Asset, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	full := variants[0]
+	query := `UPDATE assets SET mime_type = $1, size = $2, width = $3, height = $4, storage_key = $5,
+		scan_status = 'clean', scan_signature = NULL
+		WHERE id = $6 RETURNING ` + assetColumns
+	asset, err := scanAsset(tx.QueryRow(query, full.mimeType, full.Size,
+		full.Width, full.Height, full.key, assetID))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range variants {
+		query := `INSERT INTO asset_variants (asset_id, name, format, width, height, size, storage_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`
+		if _, err := tx.Exec(query, asset.ID, v.Name, v.Format, v.Width, v.Height, v.Size, v.key); err != nil {
+			return nil, err
+		}
+	}
+
+	return asset, tx.Commit()
+}
+
+func (h *UploadHandler) respondWithAsset(c *gin.Context, asset *models.Asset) {
+	switch asset.ScanStatus {
+	case "rejected":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "File was rejected by the malware scan", "asset": asset})
+		return
+	case "pending":
+		c.JSON(http.StatusAccepted, gin.H{"message": "File is quarantined until it has been scanned", "id": asset.ID, "asset": asset})
+		return
+	}
+
+	if err := attachVariants(h.db, []*models.Asset{asset}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch asset variants"})
 		return
 	}
 
-	// Return file URL
-	fileURL := fmt.Sprintf("/uploads/%s", filename)
 	c.JSON(http.StatusOK, gin.H{
-		"url":  fileURL,
-		"name": file.Filename,
-		"size": file.Size,
+		"id":       asset.ID,
+		"url":      asset.URL,
+		"name":     asset.OriginalName,
+		"size":     asset.Size,
+		"variants": asset.Variants,
+		"asset":    asset,
 	})
 }
 
-func isValidImageType(contentType string) bool {
-	validTypes := []string{
-		"image/jpeg",
-		"image/jpg",
-		"image/png",
-		"image/gif",
-		"image/webp",
+// attachVariants loads the variants of each asset.
+func attachVariants(db *sql.DB, assets []*models.Asset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+	byID := make(map[string]*models.Asset, len(assets))
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		byID[asset.ID] = asset
+		asset.Variants = []models.AssetVariant{}
+		ids = append(ids, asset.ID)
+	}
+
+	query := `SELECT asset_id, name, format, width, height, size, storage_key FROM asset_variants
+		WHERE asset_id = ANY($1::uuid[]) ORDER BY asset_id, width, format`
+	rows, err := db.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assetID, key string
+		var v models.AssetVariant
+		if err := rows.Scan(&assetID, &v.Name, &v.Format, &v.Width, &v.Height, &v.Size, &key); err != nil {
+			return err
+		}
+		v.URL = "/" + key
+		byID[assetID].Variants = append(byID[assetID].Variants, v)
 	}
+	return rows.Err()
+}
 
-	for _, validType := range validTypes {
-		if strings.EqualFold(contentType, validType) {
-			return true
+// deleteUnreferenced deletes the stored objects that no asset, variant or
+// font refers to any more. Identical uploads share their files, so a key
+// may outlive the row it was collected from.
+func deleteUnreferenced(ctx context.Context, db *sql.DB, store storage.Storage, keys []string) {
+	query := `SELECT EXISTS(SELECT 1 FROM assets WHERE storage_key = $1)
+		OR EXISTS(SELECT 1 FROM asset_variants WHERE storage_key = $1)
+		OR EXISTS(SELECT 1 FROM fonts WHERE storage_key = $1)`
+	for _, key := range keys {
+		var referenced bool
+		if err := db.QueryRow(query, key).Scan(&referenced); err != nil {
+			log.Printf("Failed to check references to %s: %v", key, err)
+			continue
+		}
+		if referenced {
+			continue
 		}
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// scanAsset scans a row selected with assetColumns followed by any extra
+// columns.
+func scanAsset(row rowScanner, extra ...interface{}) (*models.Asset, error) {
+	var asset models.Asset
+	var key string
+	dest := []interface{}{&asset.ID, &asset.OwnerID, &asset.OriginalName, &asset.MimeType, &asset.Size,
+		&asset.Width, &asset.Height, &asset.Hash, &key, &asset.Visibility, &asset.TeamID, &asset.FolderID, &asset.ScanStatus,
+		&asset.ScanSignature, &asset.KeepOriginal, &asset.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
 	}
-	return false
+	asset.URL = "/" + key
+	asset.Tags = []string{}
+	return &asset, nil
 }
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/models"
+	"canvas-designer-backend/internal/remote"
+)
+
+// ImportImage fetches an image from a URL on the user's behalf and stores
+// it like an uploaded file.
+func (h *UploadHandler) ImportImage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ImportAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = "private"
+	}
+	if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+
+	data, name, err := h.fetcher.Fetch(c.Request.Context(), req.URL)
+	if err != nil {
+		switch {
+		case errors.Is(err, remote.ErrInvalidURL), errors.Is(err, remote.ErrBlockedAddress), errors.Is(err, remote.ErrTooManyHops):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, remote.ErrTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum size is 10MB"})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch image"})
+		}
+		return
+	}
+
+	if req.Name != "" {
+		name = req.Name
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+
+	h.ingest(c, userID.(string), req.TeamID, name, req.Visibility, data, false)
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"canvas-designer-backend/internal/models"
+)
+
+// Resumable uploads are sent as fixed-size chunks (the last may be
+// shorter), each stored as its own object until the upload is completed.
+// Sessions expire uploadSessionTTL after their last chunk.
+const (
+	partialPrefix    = "partial/"
+	defaultChunkSize = 5 * 1024 * 1024
+	minChunkSize     = 256 * 1024
+	maxChunkSize     = 16 * 1024 * 1024
+	uploadSessionTTL = 24 * time.Hour
+)
+
+const uploadSessionColumns = `id, filename, size, chunk_size, visibility, team_id, created_at, expires_at`
+
+// CreateUploadSession starts a resumable upload of up to maxUploadSize.
+func (h *UploadHandler) CreateUploadSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Size > h.maxUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large. Maximum size is %d bytes", h.maxUploadSize)})
+		return
+	}
+	if req.ChunkSize == 0 {
+		req.ChunkSize = defaultChunkSize
+	}
+	if req.ChunkSize < minChunkSize || req.ChunkSize > maxChunkSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("chunk_size must be between %d and %d bytes", minChunkSize, maxChunkSize)})
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = "private"
+	}
+	if !requireTeamMember(c, h.db, req.TeamID, userID.(string)) {
+		return
+	}
+
+	usage, err := storageUsage(h.db, userID.(string), h.defaultQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+		return
+	}
+	if usage.UsedBytes+req.Size > usage.QuotaBytes {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Storage quota exceeded", "usage": usage})
+		return
+	}
+
+	query := `INSERT INTO upload_sessions (owner_id, team_id, filename, size, chunk_size, visibility, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + uploadSessionColumns
+	session, err := scanUploadSession(h.db.QueryRow(query, userID, nullableID(req.TeamID), req.Filename, req.Size, req.ChunkSize,
+		req.Visibility, time.Now().Add(uploadSessionTTL)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetUploadSession reports which chunks have been received, so a client
+// can resume after a failure.
+func (h *UploadHandler) GetUploadSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := h.findUploadSession(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// PutUploadChunk stores one chunk. The X-Chunk-SHA256 header must hold the
+// hex SHA-256 of the body. Sending a chunk again replaces it.
+func (h *UploadHandler) PutUploadChunk(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := h.findUploadSession(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= session.TotalChunks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chunk index must be between 0 and %d", session.TotalChunks-1)})
+		return
+	}
+	expectedSize := int64(session.ChunkSize)
+	if index == session.TotalChunks-1 {
+		expectedSize = session.Size - int64(index)*int64(session.ChunkSize)
+	}
+
+	checksum := strings.ToLower(c.GetHeader("X-Chunk-SHA256"))
+	if len(checksum) != sha256.Size*2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Chunk-SHA256 header is required"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, expectedSize))
+	if err != nil || int64(len(data)) != expectedSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chunk %d must be %d bytes", index, expectedSize)})
+		return
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chunk checksum mismatch"})
+		return
+	}
+
+	key := chunkKey(session.ID, index)
+	if err := h.store.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+		log.Printf("Failed to store upload chunk: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		return
+	}
+
+	query := `INSERT INTO upload_chunks (session_id, chunk_index, size, checksum) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (session_id, chunk_index) DO UPDATE SET size = EXCLUDED.size, checksum = EXCLUDED.checksum`
+	if _, err := h.db.Exec(query, session.ID, index, len(data), checksum); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+		return
+	}
+	h.db.Exec(`UPDATE upload_sessions SET expires_at = $1 WHERE id = $2`, time.Now().Add(uploadSessionTTL), session.ID)
+
+	session, err = h.findUploadSession(session.ID, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch upload"})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// CompleteUpload assembles the chunks and processes the file like a single
+// upload, except that larger images are accepted and the original file is
+// kept. The session is removed once the asset is recorded; when processing
+// fails the chunks stay so the client can retry.
+func (h *UploadHandler) CompleteUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CompleteUploadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	session, err := h.findUploadSession(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	if len(session.ReceivedChunks) != session.TotalChunks {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is missing chunks", "upload": session})
+		return
+	}
+
+	data := make([]byte, 0, session.Size)
+	for index := 0; index < session.TotalChunks; index++ {
+		chunk, err := h.readObject(c.Request.Context(), chunkKey(session.ID, index))
+		if err != nil {
+			log.Printf("Failed to read upload chunk: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assemble upload"})
+			return
+		}
+		data = append(data, chunk...)
+	}
+	if int64(len(data)) != session.Size {
+		c.JSON(http.StatusConflict, gin.H{"error": "Assembled upload has the wrong size"})
+		return
+	}
+	if req.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(req.SHA256) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File checksum mismatch"})
+			return
+		}
+	}
+
+	// The uploader may have left the team since the upload started
+	teamID := stringValue(session.TeamID)
+	if !requireTeamMember(c, h.db, teamID, userID.(string)) {
+		return
+	}
+
+	// Keep the chunks until the asset is recorded so a failed attempt can
+	// be retried
+	if h.ingest(c, userID.(string), teamID, session.Filename, session.Visibility, data, true) {
+		h.deleteUploadSession(c.Request.Context(), session)
+	}
+}
+
+// CancelUpload abandons a resumable upload and deletes its chunks.
+func (h *UploadHandler) CancelUpload(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := h.findUploadSession(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	h.deleteUploadSession(c.Request.Context(), session)
+	c.JSON(http.StatusOK, gin.H{"message": "Upload cancelled"})
+}
+
+// PurgeExpiredUploads deletes abandoned resumable uploads and their chunks.
+func (h *UploadHandler) PurgeExpiredUploads() {
+	rows, err := h.db.Query(`SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE expires_at <= NOW() LIMIT 100`)
+	if err != nil {
+		log.Printf("Failed to fetch expired uploads: %v", err)
+		return
+	}
+	var sessions []*models.UploadSession
+	for rows.Next() {
+		if session, err := scanUploadSession(rows); err == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	rows.Close()
+
+	for _, session := range sessions {
+		h.deleteUploadSession(context.Background(), session)
+	}
+	if len(sessions) > 0 {
+		log.Printf("Purged %d expired uploads", len(sessions))
+	}
+}
+
+func (h *UploadHandler) findUploadSession(id, userID string) (*models.UploadSession, error) {
+	query := `SELECT ` + uploadSessionColumns + ` FROM upload_sessions WHERE id = $1 AND owner_id = $2 AND expires_at > NOW()`
+	session, err := scanUploadSession(h.db.QueryRow(query, id, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.db.Query(`SELECT chunk_index, size FROM upload_chunks WHERE session_id = $1 ORDER BY chunk_index`, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var index int
+		var size int64
+		if err := rows.Scan(&index, &size); err != nil {
+			return nil, err
+		}
+		session.ReceivedChunks = append(session.ReceivedChunks, index)
+		session.ReceivedBytes += size
+	}
+	return session, rows.Err()
+}
+
+// deleteUploadSession removes the session's chunks from storage, then its
+// rows.
+func (h *UploadHandler) deleteUploadSession(ctx context.Context, session *models.UploadSession) {
+	for index := 0; index < session.TotalChunks; index++ {
+		if err := h.store.Delete(ctx, chunkKey(session.ID, index)); err != nil {
+			log.Printf("Failed to delete upload chunk: %v", err)
+		}
+	}
+	if _, err := h.db.Exec(`DELETE FROM upload_sessions WHERE id = $1`, session.ID); err != nil {
+		log.Printf("Failed to delete upload %s: %v", session.ID, err)
+	}
+}
+
+func scanUploadSession(row rowScanner) (*models.UploadSession, error) {
+	var session models.UploadSession
+	err := row.Scan(&session.ID, &session.Filename, &session.Size, &session.ChunkSize, &session.Visibility,
+		&session.TeamID, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	session.TotalChunks = int((session.Size + int64(session.ChunkSize) - 1) / int64(session.ChunkSize))
+	session.ReceivedChunks = []int{}
+	return &session, nil
+}
+
+func chunkKey(sessionID string, index int) string {
+	return fmt.Sprintf("%s%s/%06d", partialPrefix, sessionID, index)
+}
@@ -0,0 +1,178 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+	GIF  Format = "gif"
+	WebP Format = "webp"
+)
+
+const (
+	// MaxDimension caps the longest side of stored images.
+	MaxDimension = 4096
+	// maxPixels rejects decompression bombs before decoding.
+	maxPixels = 50_000_000
+	// MaxOriginalPixels is the higher limit for uploads whose original is
+	// kept, such as high-resolution print assets.
+	MaxOriginalPixels = 150_000_000
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image dimensions too large")
+)
+
+func (f Format) MimeType() string {
+	return "image/" + string(f)
+}
+
+func (f Format) Ext() string {
+	if f == JPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Sniff identifies an image format from its magic bytes.
+func Sniff(data []byte) (Format, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, true
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, true
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return GIF, true
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return WebP, true
+	}
+	return "", false
+}
+
+// Decode decodes data as format, rotating JPEGs upright according to their
+// EXIF orientation. Metadata is not carried over, so re-encoding the result
+// strips EXIF.
+func Decode(data []byte, format Format) (image.Image, error) {
+	return DecodeLimit(data, format, maxPixels)
+}
+
+// DecodeLimit is Decode with a custom limit on the number of pixels.
+func DecodeLimit(data []byte, format Format, limit int64) (image.Image, error) {
+	config, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > limit {
+		return nil, ErrTooLarge
+	}
+
+	var img image.Image
+	r := bytes.NewReader(data)
+	switch format {
+	case JPEG:
+		img, err = jpeg.Decode(r)
+	case PNG:
+		img, err = png.Decode(r)
+	case GIF:
+		img, err = gif.Decode(r)
+	case WebP:
+		img, err = webp.Decode(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if format == JPEG {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+func decodeConfig(data []byte, format Format) (image.Config, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case JPEG:
+		return jpeg.DecodeConfig(r)
+	case PNG:
+		return png.DecodeConfig(r)
+	case GIF:
+		return gif.DecodeConfig(r)
+	case WebP:
+		return webp.DecodeConfig(r)
+	}
+	return image.Config{}, ErrUnsupportedFormat
+}
+
+// Fit scales img down so it fits within maxWidth x maxHeight, keeping its
+// aspect ratio. Images that already fit are returned unchanged.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= maxWidth && b.Dy() <= maxHeight {
+		return img
+	}
+	scale := float64(maxWidth) / float64(b.Dx())
+	if s := float64(maxHeight) / float64(b.Dy()); s < scale {
+		scale = s
+	}
+	return Resize(img, max(1, int(float64(b.Dx())*scale+0.5)), max(1, int(float64(b.Dy())*scale+0.5)))
+}
+
+// Cover scales img to fill width x height, cropping the overflow evenly
+// from both sides.
+func Cover(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	// Pick the largest source rectangle with the target aspect ratio
+	crop := b
+	if b.Dx()*height > b.Dy()*width {
+		w := max(1, b.Dy()*width/height)
+		crop.Min.X = b.Min.X + (b.Dx()-w)/2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := max(1, b.Dx()*height/width)
+		crop.Min.Y = b.Min.Y + (b.Dy()-h)/2
+		crop.Max.Y = crop.Min.Y + h
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+// Resize scales img to exactly width x height.
+func Resize(img image.Image, width, height int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// Encode writes img as format. Quality only applies to JPEG; WebP output is
+// lossless.
+func Encode(w io.Writer, img image.Image, format Format, quality int) error {
+	switch format {
+	case JPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case PNG:
+		return png.Encode(w, img)
+	case WebP:
+		return nativewebp.Encode(w, img, nil)
+	}
+	return ErrUnsupportedFormat
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"time"
+)
+
+// AccountPurger deletes accounts whose deletion grace period has elapsed,
+// along with their stored files.
+type AccountPurger interface {
+	PurgeDeletedAccounts()
+}
+
+// StartAccountPurge periodically purges deleted accounts.
+func StartAccountPurge(purger AccountPurger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purger.PurgeDeletedAccounts()
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"time"
+)
+
+// Rescanner scans uploads that are still quarantined, e.g. because the
+// scanner was unavailable when they were uploaded.
+type Rescanner interface {
+	RescanQuarantined()
+}
+
+// StartQuarantineRescan periodically retries scanning quarantined uploads.
+func StartQuarantineRescan(rescanner Rescanner, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			<-ticker.C
+			rescanner.RescanQuarantined()
+		}
+	}()
+}
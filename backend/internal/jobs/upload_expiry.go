@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"time"
+)
+
+// UploadPurger deletes resumable uploads that were abandoned before they
+// were completed.
+type UploadPurger interface {
+	PurgeExpiredUploads()
+}
+
+// StartUploadExpiry periodically purges expired resumable uploads.
+func StartUploadExpiry(purger UploadPurger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purger.PurgeExpiredUploads()
+			<-ticker.C
+		}
+	}()
+}
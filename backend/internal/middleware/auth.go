@@ -1,15 +1,24 @@
 package middleware
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
 	"canvas-designer-backend/internal/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+var (
+	errAccountSuspended = errors.New("account suspended")
+	errSessionRevoked   = errors.New("session revoked")
+)
+
+func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -19,12 +28,22 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
 			c.Abort()
 			return
 		}
 
+		if parts[0] == "ApiKey" {
+			if !authenticateAPIKey(c, db, parts[1]) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		tokenString := parts[1]
 		claims, err := utils.ValidateJWT(tokenString)
 		if err != nil {
@@ -33,12 +52,21 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("userID", claims.UserID)
+		if err := authenticateSession(c, db, claims); err != nil {
+			if err == errAccountSuspended {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			}
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
-func OptionalAuthMiddleware() gin.HandlerFunc {
+func OptionalAuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -47,7 +75,17 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		}
 
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 {
+			c.Next()
+			return
+		}
+
+		if parts[0] == "ApiKey" {
+			authenticateAPIKey(c, db, parts[1])
+			c.Next()
+			return
+		}
+		if parts[0] != "Bearer" {
 			c.Next()
 			return
 		}
@@ -59,11 +97,120 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("userID", claims.UserID)
+		authenticateSession(c, db, claims)
+		c.Next()
+	}
+}
+
+// AdminMiddleware must be layered after AuthMiddleware. Impersonation tokens
+// never grant admin access, even when impersonating another admin.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("userRole")
+		_, impersonating := c.Get("impersonatorID")
+		if role != "admin" || impersonating {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope restricts a route to JWT sessions and API keys granted scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if HasScope(c, scope) {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing required scope: " + scope})
+		c.Abort()
+	}
+}
+
+// HasScope reports whether the request may use scope. JWT sessions have
+// every scope; API keys only those they were granted.
+func HasScope(c *gin.Context, scope string) bool {
+	scopes, isAPIKey := c.Get("apiKeyScopes")
+	if !isAPIKey {
+		return true
+	}
+	for _, granted := range scopes.([]string) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionOnly rejects requests authenticated with an API key, for routes
+// such as key management that must not be reachable by automation. It also
+// rejects impersonation tokens, so an admin acting as a user cannot mint
+// credentials or change account details that outlive the impersonation.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("apiKeyID"); isAPIKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a user session"})
+			c.Abort()
+			return
+		}
+		if _, impersonating := c.Get("impersonatorID"); impersonating {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available while impersonating"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
+// authenticateSession checks that the token's user still exists, is not
+// suspended and has not had its sessions revoked since the token was issued.
+func authenticateSession(c *gin.Context, db *sql.DB, claims *utils.JWTClaims) error {
+	query := `SELECT role, suspended_at, sessions_revoked_at FROM users WHERE id = $1`
+	var role string
+	var suspendedAt, revokedAt sql.NullTime
+	if err := db.QueryRow(query, claims.UserID).Scan(&role, &suspendedAt, &revokedAt); err != nil {
+		return err
+	}
+
+	if suspendedAt.Valid {
+		return errAccountSuspended
+	}
+	// JWT timestamps have second precision
+	if revokedAt.Valid && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(revokedAt.Time.Truncate(time.Second)) {
+		return errSessionRevoked
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("userRole", role)
+	if claims.ImpersonatorID != "" {
+		c.Set("impersonatorID", claims.ImpersonatorID)
+	}
+	return nil
+}
+
+func authenticateAPIKey(c *gin.Context, db *sql.DB, key string) bool {
+	query := `SELECT k.id, k.user_id, k.scopes, u.role FROM api_keys k JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW()) AND u.suspended_at IS NULL`
+	var keyID, userID, role string
+	var scopes []string
+	err := db.QueryRow(query, utils.HashAPIKey(key)).Scan(&keyID, &userID, pq.Array(&scopes), &role)
+	if err != nil {
+		return false
+	}
+
+	// Throttle last-used writes to one per minute per key
+	db.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`, keyID)
+
+	c.Set("userID", userID)
+	c.Set("userRole", role)
+	c.Set("apiKeyID", keyID)
+	c.Set("apiKeyScopes", scopes)
+	return true
+}
+
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
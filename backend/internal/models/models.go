@@ -9,21 +9,103 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  string    `json:"-"`
 	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	SuspendedAt         *time.Time `json:"suspended_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// AdminUser is the user view returned by the admin API.
+type AdminUser struct {
+	User
+	DesignCount int `json:"design_count"`
+}
+
+type SystemStats struct {
+	Users          int   `json:"users"`
+	ActiveUsers    int   `json:"active_users"`
+	SuspendedUsers int   `json:"suspended_users"`
+	Designs        int   `json:"designs"`
+	Templates      int   `json:"templates"`
+	StorageFiles   int64 `json:"storage_files"`
+	StorageBytes   int64 `json:"storage_bytes"`
+}
+
+type AuditLog struct {
+	ID        string      `json:"id"`
+	ActorID   *string     `json:"actor_id"`
+	Action    string      `json:"action"`
+	TargetID  *string     `json:"target_id"`
+	IPAddress *string     `json:"ip_address"`
+	// ImpersonatorID is the admin who acted as the actor, if any
+	ImpersonatorID *string `json:"impersonator_id"`
+	Metadata  interface{} `json:"metadata"`
+	CreatedAt time.Time   `json:"created_at"`
 }
 
 type Design struct {
 	ID          string     `json:"id"`
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
-	CanvasData  interface{} `json:"canvas_data"`
+	// CanvasData is left out of design lists unless asked for
+	CanvasData  interface{} `json:"canvas_data,omitempty"`
 	Thumbnail   string     `json:"thumbnail"`
 	UserID      string     `json:"user_id"`
+	TeamID      *string    `json:"team_id"`
+	FolderID    *string    `json:"folder_id"`
+	TemplateID  *string    `json:"template_id"`
+	Starred     bool       `json:"starred"`
+	// Path is the breadcrumb trail of the design's folder, from the root
+	Path        []FolderCrumb `json:"path,omitempty"`
+	// AccessedAt is when the caller last opened or saved the design; it is
+	// only set in the recent view
+	AccessedAt  *time.Time `json:"accessed_at,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
+// DesignSearchResult is a design matched by full-text search. The
+// highlights are HTML-escaped with matches wrapped in <mark>.
+type DesignSearchResult struct {
+	Design
+	TitleHighlight string  `json:"title_highlight"`
+	// Snippet is taken from the description and the canvas text
+	Snippet        string  `json:"snippet"`
+	Rank           float64 `json:"rank"`
+}
+
+type DesignFolder struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	ParentID    *string       `json:"parent_id"`
+	OwnerID     string        `json:"owner_id"`
+	TeamID      *string       `json:"team_id"`
+	DesignCount int           `json:"design_count"`
+	FolderCount int           `json:"folder_count"`
+	// Role is the caller's access through a share ("viewer" or "editor"),
+	// or "owner" for folders in their own workspaces
+	Role        string        `json:"role"`
+	Path        []FolderCrumb `json:"path,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// FolderCrumb is one step of a folder's breadcrumb path.
+type FolderCrumb struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type FolderShare struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Template struct {
 	ID          string     `json:"id"`
 	Title       string     `json:"title"`
@@ -31,7 +113,266 @@ type Template struct {
 	Category    string     `json:"category"`
 	Thumbnail   string     `json:"thumbnail"`
 	CanvasData  *string    `json:"canvas_data"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	Tags        []string   `json:"tags"`
+	OwnerID     *string    `json:"owner_id"`
+	TeamID      *string    `json:"team_id"`
+	Visibility  string     `json:"visibility"`
+	IsPublished bool       `json:"is_published"`
+	UsageCount  int        `json:"usage_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type TemplateCategory struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type GenerationJob struct {
+	ID          string     `json:"id"`
+	TemplateID  string     `json:"template_id"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Completed   int        `json:"completed"`
+	Export      string     `json:"export,omitempty"`
+	DesignIDs   []string   `json:"design_ids"`
+	Error       string     `json:"error,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+type Asset struct {
+	ID            string         `json:"id"`
+	OwnerID       string         `json:"owner_id"`
+	OriginalName  string         `json:"original_name"`
+	MimeType      string         `json:"mime_type"`
+	Size          int64          `json:"size"`
+	Width         *int           `json:"width"`
+	Height        *int           `json:"height"`
+	Hash          string         `json:"hash"`
+	Visibility    string         `json:"visibility"`
+	TeamID        *string        `json:"team_id"`
+	FolderID      *string        `json:"folder_id"`
+	ScanStatus    string         `json:"scan_status"`
+	ScanSignature *string        `json:"scan_signature,omitempty"`
+	Tags          []string       `json:"tags"`
+	UsageCount    int            `json:"usage_count"`
+	URL           string         `json:"url"`
+	Variants      []AssetVariant `json:"variants,omitempty"`
+	// KeepOriginal stores the uploaded file as an "original" variant
+	KeepOriginal  bool           `json:"-"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type Font struct {
+	ID           string    `json:"id"`
+	OwnerID      string    `json:"owner_id"`
+	TeamID       *string   `json:"team_id"`
+	Family       string    `json:"family"`
+	Weight       int       `json:"weight"`
+	Style        string    `json:"style"`
+	Format       string    `json:"format"`
+	OriginalName string    `json:"original_name"`
+	Size         int64     `json:"size"`
+	Hash         string    `json:"hash"`
+	URL          string    `json:"url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type BrandKit struct {
+	ID           string         `json:"id"`
+	OwnerID      string         `json:"owner_id"`
+	TeamID       *string        `json:"team_id"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	Palettes     []BrandPalette `json:"palettes"`
+	FontPairings []FontPairing  `json:"font_pairings"`
+	Logos        []BrandLogo    `json:"logos"`
+	TextStyles   []TextStyle    `json:"text_styles"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+type BrandPalette struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Colors []string `json:"colors" binding:"required,min=1,max=64,dive,hexcolor"`
+}
+
+type BrandFont struct {
+	Family string `json:"family" binding:"required,max=255"`
+	Weight int    `json:"weight,omitempty" binding:"omitempty,min=1,max=1000"`
+	Style  string `json:"style,omitempty" binding:"omitempty,oneof=normal italic"`
+}
+
+type FontPairing struct {
+	Name    string    `json:"name" binding:"required,max=100"`
+	Heading BrandFont `json:"heading"`
+	Body    BrandFont `json:"body"`
+}
+
+type BrandLogo struct {
+	AssetID string `json:"asset_id" binding:"required,uuid"`
+	Name    string `json:"name" binding:"max=100"`
+	URL     string `json:"url,omitempty"`
+}
+
+type TextStyle struct {
+	Name       string  `json:"name" binding:"required,max=100"`
+	FontFamily string  `json:"font_family" binding:"required,max=255"`
+	FontSize   float64 `json:"font_size" binding:"required,gt=0,max=1000"`
+	FontWeight int     `json:"font_weight,omitempty" binding:"omitempty,min=1,max=1000"`
+	Fill       string  `json:"fill,omitempty" binding:"omitempty,hexcolor"`
+	LineHeight float64 `json:"line_height,omitempty" binding:"omitempty,gt=0,max=10"`
+}
+
+type Team struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Role        string       `json:"role"`
+	MemberCount int          `json:"member_count"`
+	Members     []TeamMember `json:"members,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+type TeamMember struct {
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type TeamInvitation struct {
+	ID        string    `json:"id"`
+	TeamID    string    `json:"team_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy *string   `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AssetFolder struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	AssetCount int       `json:"asset_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type AssetReference struct {
+	DesignID  string    `json:"design_id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type StorageUsage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+	Assets     int   `json:"assets"`
+}
+
+type AssetVariant struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+	URL    string `json:"url"`
+}
+
+type UploadSession struct {
+	ID             string    `json:"id"`
+	Filename       string    `json:"filename"`
+	Size           int64     `json:"size"`
+	ChunkSize      int       `json:"chunk_size"`
+	TotalChunks    int       `json:"total_chunks"`
+	ReceivedChunks []int     `json:"received_chunks"`
+	ReceivedBytes  int64     `json:"received_bytes"`
+	Visibility     string    `json:"visibility"`
+	TeamID         *string   `json:"team_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type CreateUploadSessionRequest struct {
+	Filename   string `json:"filename" binding:"required,max=255"`
+	Size       int64  `json:"size" binding:"required,min=1"`
+	ChunkSize  int    `json:"chunk_size"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public private"`
+	TeamID     string `json:"team_id" binding:"omitempty,uuid"`
+}
+
+type CompleteUploadRequest struct {
+	// SHA256 optionally verifies the assembled file (hex encoded)
+	SHA256 string `json:"sha256" binding:"omitempty,len=64,hexadecimal"`
+}
+
+type ImportAssetRequest struct {
+	URL string `json:"url" binding:"required,url"`
+	// Name defaults to the last segment of the URL path
+	Name       string `json:"name" binding:"max=255"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public private"`
+	TeamID     string `json:"team_id" binding:"omitempty,uuid"`
+}
+
+type UpdateAssetRequest struct {
+	Name *string `json:"name" binding:"omitempty,min=1,max=255"`
+	// FolderID moves the asset; an empty string moves it out of any folder
+	FolderID *string   `json:"folder_id"`
+	Tags     *[]string `json:"tags"`
+}
+
+type TeamRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type UpdateTeamMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+type InviteTeamMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+type AcceptTeamInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TransferDesignRequest moves a design into a team, or back to the
+// caller's personal space when TeamID is null.
+type TransferDesignRequest struct {
+	TeamID *string `json:"team_id" binding:"omitempty,uuid"`
+}
+
+type BrandKitRequest struct {
+	// TeamID creates the kit in a team's workspace; it is ignored on update
+	TeamID       string         `json:"team_id" binding:"omitempty,uuid"`
+	Name         string         `json:"name" binding:"required,max=255"`
+	Description  string         `json:"description" binding:"max=1000"`
+	Palettes     []BrandPalette `json:"palettes" binding:"max=20,dive"`
+	FontPairings []FontPairing  `json:"font_pairings" binding:"max=20,dive"`
+	Logos        []BrandLogo    `json:"logos" binding:"max=20,dive"`
+	TextStyles   []TextStyle    `json:"text_styles" binding:"max=50,dive"`
+}
+
+// BrandCheckRequest names a saved design or carries unsaved canvas data.
+type BrandCheckRequest struct {
+	DesignID   string      `json:"design_id" binding:"omitempty,uuid"`
+	CanvasData interface{} `json:"canvas_data"`
+}
+
+type AssetFolderRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type UpdateStorageQuotaRequest struct {
+	// QuotaBytes of nil restores the default quota
+	QuotaBytes *int64 `json:"quota_bytes" binding:"omitempty,min=0"`
 }
 
 type Element struct {
@@ -58,10 +399,112 @@ type UpdateProfileRequest struct {
 	Name string `json:"name" binding:"required"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+type CreateTemplateRequest struct {
+	Title       string      `json:"title" binding:"required"`
+	Description string      `json:"description"`
+	Category    string      `json:"category" binding:"required"`
+	CanvasData  interface{} `json:"canvas_data" binding:"required"`
+	Tags        []string    `json:"tags"`
+	IsPublished bool        `json:"is_published"`
+}
+
+type UpdateTemplateRequest struct {
+	Title       *string      `json:"title"`
+	Description *string      `json:"description"`
+	Category    *string      `json:"category"`
+	CanvasData  *interface{} `json:"canvas_data"`
+	Tags        *[]string    `json:"tags"`
+}
+
+type SaveAsTemplateRequest struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Category    string   `json:"category" binding:"required"`
+	Tags        []string `json:"tags"`
+}
+
+type PromoteDesignRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Category    string   `json:"category" binding:"required"`
+	Tags        []string `json:"tags"`
+	IsPublished bool     `json:"is_published"`
+}
+
+type GenerateDesignsRequest struct {
+	Records []map[string]string `json:"records" binding:"required,min=1"`
+	// Title is a pattern for each design's title, e.g. "Certificate - {{name}}"
+	Title  string `json:"title"`
+	Export string `json:"export" binding:"omitempty,oneof=svg"`
+}
+
 type CreateDesignRequest struct {
 	Title       string     `json:"title" binding:"required"`
 	Description string     `json:"description"`
 	CanvasData  interface{} `json:"canvas_data"`
+	// TemplateID clones the template's canvas_data when CanvasData is empty
+	TemplateID  *string    `json:"template_id"`
+	// TeamID creates the design in a team's workspace
+	TeamID string `json:"team_id" binding:"omitempty,uuid"`
+	// FolderID must be a folder in the same workspace
+	FolderID string `json:"folder_id" binding:"omitempty,uuid"`
+}
+
+type UseTemplateRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	TeamID      string `json:"team_id" binding:"omitempty,uuid"`
+}
+
+type DesignFolderRequest struct {
+	Name     string  `json:"name" binding:"required,max=255"`
+	ParentID *string `json:"parent_id" binding:"omitempty,uuid"`
+	// TeamID creates the folder in a team's workspace; it is ignored on
+	// update and for subfolders, which follow their parent
+	TeamID   string  `json:"team_id" binding:"omitempty,uuid"`
+}
+
+type UpdateDesignFolderRequest struct {
+	Name *string `json:"name" binding:"omitempty,min=1,max=255"`
+	// ParentID moves the folder when sent; "" moves it to the root
+	ParentID *string `json:"parent_id" binding:"omitempty,uuid"`
+}
+
+// MoveDesignRequest files a design in a folder, or at the root when
+// FolderID is null or "".
+type MoveDesignRequest struct {
+	FolderID *string `json:"folder_id" binding:"omitempty,uuid"`
+}
+
+type ShareFolderRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=viewer editor"`
 }
 
 type UpdateDesignRequest struct {
@@ -69,3 +512,29 @@ type UpdateDesignRequest struct {
 	Description *string    `json:"description"`
 	CanvasData  *interface{} `json:"canvas_data"`
 }
+
+// API key scopes
+const (
+	ScopeDesignsRead    = "designs:read"
+	ScopeDesignsWrite   = "designs:write"
+	ScopeExport         = "export"
+	ScopeTemplatesAdmin = "templates:admin"
+)
+
+var ValidScopes = []string{ScopeDesignsRead, ScopeDesignsWrite, ScopeExport, ScopeTemplatesAdmin}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Policy describes how many attempts a key gets and how the lockout grows.
+// Once Count reaches Threshold the key is locked for BaseLockout, doubling
+// with every further attempt up to MaxLockout.
+type Policy struct {
+	Threshold   int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// Result reports the outcome of recording an attempt.
+type Result struct {
+	Count int
+	// Locked is true when this attempt triggered a new lockout.
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// Allow returns how long the caller must wait before key may try again, or
+// zero when the key is not locked.
+func (l *Limiter) Allow(key string) (time.Duration, error) {
+	entry, err := l.store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	now := l.now()
+	if now.Before(entry.LockedUntil) {
+		return entry.LockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Record counts an attempt against key and locks it once the policy
+// threshold is reached.
+func (l *Limiter) Record(key string) (Result, error) {
+	now := l.now()
+	entry, err := l.store.Incr(key, l.policy.Window, now)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Count: entry.Count}
+	if entry.Count < l.policy.Threshold {
+		return result, nil
+	}
+
+	lockout := l.lockout(entry.Count)
+	if err := l.store.Lock(key, now.Add(lockout)); err != nil {
+		return result, err
+	}
+	result.Locked = true
+	result.RetryAfter = lockout
+
+	return result, nil
+}
+
+func (l *Limiter) Reset(key string) error {
+	return l.store.Reset(key)
+}
+
+func (l *Limiter) lockout(count int) time.Duration {
+	lockout := l.policy.BaseLockout
+	for i := l.policy.Threshold; i < count; i++ {
+		lockout *= 2
+		if lockout >= l.policy.MaxLockout {
+			return l.policy.MaxLockout
+		}
+	}
+	return lockout
+}
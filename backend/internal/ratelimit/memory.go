@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps attempts in process memory. It is only suitable when a
+// single backend instance is serving traffic.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*Entry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+func (s *MemoryStore) Incr(key string, window time.Duration, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(window, now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &Entry{}
+		s.entries[key] = entry
+	}
+	if now.Sub(entry.LastAttempt) > window && !now.Before(entry.LockedUntil) {
+		entry.Count = 0
+	}
+	entry.Count++
+	entry.LastAttempt = now
+
+	return *entry, nil
+}
+
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		return *entry, nil
+	}
+	return Entry{}, nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &Entry{}
+		s.entries[key] = entry
+	}
+	entry.LockedUntil = until
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops stale entries at most once a minute so the map cannot grow
+// without bound. Callers must hold s.mu.
+func (s *MemoryStore) sweep(window time.Duration, now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if now.Sub(entry.LastAttempt) > window && !now.Before(entry.LockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps attempts in the auth_attempts table so that every
+// backend instance sees the same counters.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Incr(key string, window time.Duration, now time.Time) (Entry, error) {
+	query := `INSERT INTO auth_attempts (key, count, last_attempt) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE
+				WHEN auth_attempts.last_attempt < $3 AND (auth_attempts.locked_until IS NULL OR auth_attempts.locked_until <= $2)
+				THEN 1
+				ELSE auth_attempts.count + 1
+			END,
+			last_attempt = $2
+		RETURNING count, last_attempt, locked_until`
+
+	var entry Entry
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRow(query, key, now, now.Add(-window)).Scan(&entry.Count, &entry.LastAttempt, &lockedUntil)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to record attempt: %w", err)
+	}
+	entry.LockedUntil = lockedUntil.Time
+
+	return entry, nil
+}
+
+func (s *PostgresStore) Get(key string) (Entry, error) {
+	query := `SELECT count, last_attempt, locked_until FROM auth_attempts WHERE key = $1`
+
+	var entry Entry
+	var lockedUntil sql.NullTime
+	err := s.db.QueryRow(query, key).Scan(&entry.Count, &entry.LastAttempt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read attempts: %w", err)
+	}
+	entry.LockedUntil = lockedUntil.Time
+
+	return entry, nil
+}
+
+func (s *PostgresStore) Lock(key string, until time.Time) error {
+	query := `UPDATE auth_attempts SET locked_until = $1 WHERE key = $2`
+	if _, err := s.db.Exec(query, until, key); err != nil {
+		return fmt.Errorf("failed to lock key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Reset(key string) error {
+	query := `DELETE FROM auth_attempts WHERE key = $1`
+	if _, err := s.db.Exec(query, key); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Entry is the attempt history tracked for a single key (an IP or an account).
+type Entry struct {
+	Count       int
+	LastAttempt time.Time
+	LockedUntil time.Time
+}
+
+// Store persists attempt entries. Implementations must make Incr atomic so
+// that several instances sharing a store count attempts correctly.
+type Store interface {
+	// Incr records an attempt at now. The count restarts from one when the
+	// previous attempt is older than window and the key is not locked.
+	Incr(key string, window time.Duration, now time.Time) (Entry, error)
+	Get(key string) (Entry, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
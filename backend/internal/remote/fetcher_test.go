@@ -0,0 +1,197 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	f := NewFetcher(1024, time.Second, false)
+	for _, rawURL := range []string{
+		server.URL,
+		strings.Replace(server.URL, "127.0.0.1", "localhost", 1),
+	} {
+		if _, _, err := f.Fetch(context.Background(), rawURL); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) error = %v, want ErrBlockedAddress", rawURL, err)
+		}
+	}
+}
+
+func TestFetchBlocksRedirectToPrivateAddress(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	// The first hop is allowed by the test dialer below; the redirect target
+	// must still be checked
+	f := NewFetcher(1024, time.Second, false)
+	transport := f.client.Transport.(*http.Transport)
+	guarded := transport.DialContext
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer redirector.Close()
+	redirectorAddr := redirector.Listener.Addr().String()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == redirectorAddr {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}
+		return guarded(ctx, network, address)
+	}
+
+	if _, _, err := f.Fetch(context.Background(), redirector.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch error = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"64:ff9b::a00:1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1::1", false},
+	}
+	for _, tt := range tests {
+		if got := isBlocked(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("isBlocked(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	data, name, err := NewFetcher(1024, time.Second, true).Fetch(context.Background(), server.URL+"/images/cat.png?size=large")
+	if err != nil {
+		t.Fatalf("Fetch error = %v", err)
+	}
+	if string(data) != "image" || name != "cat.png" {
+		t.Errorf("Fetch = %q, %q; want \"image\", \"cat.png\"", data, name)
+	}
+}
+
+func TestFetchRejectsInvalidURLs(t *testing.T) {
+	f := NewFetcher(1024, time.Second, true)
+	for _, rawURL := range []string{"ftp://example.com/a.png", "file:///etc/passwd", "javascript:alert(1)", "http://", "not a url"} {
+		if _, _, err := f.Fetch(context.Background(), rawURL); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("Fetch(%q) error = %v, want ErrInvalidURL", rawURL, err)
+		}
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, server.URL+"/loop", http.StatusFound)
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/a.png", http.StatusFound)
+		case "/two":
+			http.Redirect(w, r, server.URL+"/one", http.StatusFound)
+		case "/one":
+			http.Redirect(w, r, server.URL+"/final.png", http.StatusFound)
+		default:
+			w.Write([]byte("image"))
+		}
+	}))
+	defer server.Close()
+
+	f := NewFetcher(1024, time.Second, true)
+	if _, name, err := f.Fetch(context.Background(), server.URL+"/two"); err != nil || name != "final.png" {
+		t.Errorf("Fetch(/two) = %q, %v; want final.png", name, err)
+	}
+	if _, _, err := f.Fetch(context.Background(), server.URL+"/loop"); !errors.Is(err, ErrTooManyHops) {
+		t.Errorf("Fetch(/loop) error = %v, want ErrTooManyHops", err)
+	}
+	if _, _, err := f.Fetch(context.Background(), server.URL+"/ftp"); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Fetch(/ftp) error = %v, want ErrInvalidURL", err)
+	}
+}
+
+func TestFetchSizeLimits(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/declared":
+			w.Write(body)
+		case "/streamed":
+			// Flushing first sends the body chunked, without a length
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			w.Write(body)
+		case "/exact":
+			w.Write(body[:1024])
+		}
+	}))
+	defer server.Close()
+
+	f := NewFetcher(1024, time.Second, true)
+	for _, path := range []string{"/declared", "/streamed"} {
+		if _, _, err := f.Fetch(context.Background(), server.URL+path); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("Fetch(%s) error = %v, want ErrTooLarge", path, err)
+		}
+	}
+	if data, _, err := f.Fetch(context.Background(), server.URL+"/exact"); err != nil || len(data) != 1024 {
+		t.Errorf("Fetch(/exact) = %d bytes, %v; want 1024 bytes", len(data), err)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	_, _, err := NewFetcher(1024, 100*time.Millisecond, true).Fetch(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("Fetch succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Fetch took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestFetchRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, _, err := NewFetcher(1024, time.Second, true).Fetch(context.Background(), server.URL); err == nil {
+		t.Error("Fetch succeeded for a 404, want an error")
+	}
+}
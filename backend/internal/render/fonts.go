@@ -0,0 +1,94 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FontFace is a font file embedded into rendered output, so text is drawn
+// with the same files the editor loads.
+type FontFace struct {
+	Family string
+	Weight int
+	Style  string
+	// Format is the CSS format() hint: truetype, opentype or woff2
+	Format   string
+	MimeType string
+	Data     []byte
+}
+
+// FontFamilies lists the font families used by text in a canvas.
+func FontFamilies(canvas interface{}) []string {
+	seen := map[string]bool{}
+	var collect func(objects interface{}, depth int)
+	collect = func(objects interface{}, depth int) {
+		list, ok := objects.([]interface{})
+		if !ok || depth > maxDepth {
+			return
+		}
+		for _, item := range list {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// fontFamily may be a CSS list such as "Lato, sans-serif"
+			for _, family := range strings.Split(str(object["fontFamily"], ""), ",") {
+				if family = strings.Trim(strings.TrimSpace(family), `"'`); family != "" {
+					seen[family] = true
+				}
+			}
+			collect(object["objects"], depth+1)
+		}
+	}
+
+	if root, ok := canvas.(map[string]interface{}); ok {
+		for _, key := range []string{"objects", "elements"} {
+			collect(root[key], 0)
+		}
+	}
+
+	families := make([]string, 0, len(seen))
+	for family := range seen {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	return families
+}
+
+// writeFontFaces declares faces as @font-face rules with data URIs.
+func writeFontFaces(buf *bytes.Buffer, faces []FontFace) {
+	if len(faces) == 0 {
+		return
+	}
+
+	var css strings.Builder
+	for _, face := range faces {
+		fmt.Fprintf(&css, `@font-face{font-family:%s;font-weight:%d;font-style:%s;src:url("data:%s;base64,%s") format("%s");}`,
+			cssString(face.Family), face.Weight, face.Style, face.MimeType, base64.StdEncoding.EncodeToString(face.Data), face.Format)
+	}
+	buf.WriteString("<defs><style>")
+	buf.WriteString(attr(css.String()))
+	buf.WriteString("</style></defs>\n")
+}
+
+// cssString quotes s as a CSS string.
+func cssString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&b, `\%x `, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
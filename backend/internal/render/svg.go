@@ -0,0 +1,287 @@
+// Package render turns serialised Fabric.js canvases into image files on the
+// server, for exports and bulk generation.
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultWidth  = 800
+	defaultHeight = 600
+	maxDepth      = 16
+)
+
+// SVG renders a Fabric canvas as a standalone SVG document. It covers the
+// object types the editor produces: shapes, lines, paths, text, images and
+// groups. Text is not wrapped beyond explicit line breaks. fonts are
+// embedded so text renders with them where available.
+func SVG(canvas interface{}, fonts ...FontFace) ([]byte, error) {
+	root, ok := canvas.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("canvas must be a JSON object")
+	}
+
+	width := number(root["width"], defaultWidth)
+	height := number(root["height"], defaultHeight)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%s" height="%s" viewBox="0 0 %s %s">`,
+		fmtNum(width), fmtNum(height), fmtNum(width), fmtNum(height))
+	buf.WriteString("\n")
+	writeFontFaces(&buf, fonts)
+
+	background := str(root["background"], "")
+	if background == "" {
+		background = str(root["backgroundColor"], "")
+	}
+	if background != "" {
+		fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", attr(background))
+	}
+
+	for _, key := range []string{"objects", "elements"} {
+		if objects, ok := root[key].([]interface{}); ok {
+			renderObjects(&buf, objects, 0)
+		}
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+func renderObjects(buf *bytes.Buffer, objects []interface{}, depth int) {
+	if depth > maxDepth {
+		return
+	}
+	for _, item := range objects {
+		if object, ok := item.(map[string]interface{}); ok {
+			renderObject(buf, object, depth)
+		}
+	}
+}
+
+func renderObject(buf *bytes.Buffer, o map[string]interface{}, depth int) {
+	if visible, ok := o["visible"].(bool); ok && !visible {
+		return
+	}
+
+	objectType := strings.ToLower(str(o["type"], ""))
+	w := number(o["width"], 0)
+	h := number(o["height"], 0)
+	if objectType == "circle" {
+		r := number(o["radius"], 0)
+		w, h = 2*r, 2*r
+	}
+
+	// Fabric positions an object by its origin point and rotates/scales
+	// around it; shapes are then drawn relative to their top-left corner.
+	ox := originFactor(str(o["originX"], "left"))
+	oy := originFactor(str(o["originY"], "top"))
+	transform := fmt.Sprintf("translate(%s %s)", fmtNum(number(o["left"], 0)), fmtNum(number(o["top"], 0)))
+	if angle := number(o["angle"], 0); angle != 0 {
+		transform += fmt.Sprintf(" rotate(%s)", fmtNum(angle))
+	}
+	sx, sy := number(o["scaleX"], 1), number(o["scaleY"], 1)
+	if flip, _ := o["flipX"].(bool); flip {
+		sx = -sx
+	}
+	if flip, _ := o["flipY"].(bool); flip {
+		sy = -sy
+	}
+	if sx != 1 || sy != 1 {
+		transform += fmt.Sprintf(" scale(%s %s)", fmtNum(sx), fmtNum(sy))
+	}
+	if ox*w != 0 || oy*h != 0 {
+		transform += fmt.Sprintf(" translate(%s %s)", fmtNum(-ox*w), fmtNum(-oy*h))
+	}
+
+	fmt.Fprintf(buf, `<g transform="%s"%s>`, transform, opacityAttr(o))
+
+	switch objectType {
+	case "rect":
+		fmt.Fprintf(buf, `<rect width="%s" height="%s" rx="%s" ry="%s"%s/>`,
+			fmtNum(w), fmtNum(h), fmtNum(number(o["rx"], 0)), fmtNum(number(o["ry"], 0)), paintAttrs(o))
+	case "circle":
+		r := w / 2
+		fmt.Fprintf(buf, `<circle cx="%s" cy="%s" r="%s"%s/>`, fmtNum(r), fmtNum(r), fmtNum(r), paintAttrs(o))
+	case "ellipse":
+		rx, ry := number(o["rx"], w/2), number(o["ry"], h/2)
+		fmt.Fprintf(buf, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`, fmtNum(rx), fmtNum(ry), fmtNum(rx), fmtNum(ry), paintAttrs(o))
+	case "triangle":
+		fmt.Fprintf(buf, `<polygon points="%s,0 %s,%s 0,%s"%s/>`, fmtNum(w/2), fmtNum(w), fmtNum(h), fmtNum(h), paintAttrs(o))
+	case "line":
+		// Line endpoints are stored relative to the object's centre
+		fmt.Fprintf(buf, `<line x1="%s" y1="%s" x2="%s" y2="%s"%s/>`,
+			fmtNum(number(o["x1"], 0)+w/2), fmtNum(number(o["y1"], 0)+h/2),
+			fmtNum(number(o["x2"], 0)+w/2), fmtNum(number(o["y2"], 0)+h/2), paintAttrs(o))
+	case "polygon", "polyline":
+		px, py := pathOffset(o)
+		fmt.Fprintf(buf, `<%s transform="translate(%s %s)" points="%s"%s/>`,
+			objectType, fmtNum(w/2-px), fmtNum(h/2-py), points(o["points"]), paintAttrs(o))
+	case "path":
+		px, py := pathOffset(o)
+		fmt.Fprintf(buf, `<path transform="translate(%s %s)" d="%s"%s/>`,
+			fmtNum(w/2-px), fmtNum(h/2-py), attr(pathData(o["path"])), paintAttrs(o))
+	case "text", "i-text", "itext", "textbox":
+		renderText(buf, o, w)
+	case "image":
+		if src := str(o["src"], ""); src != "" {
+			fmt.Fprintf(buf, `<image width="%s" height="%s" preserveAspectRatio="none" xlink:href="%s"/>`, fmtNum(w), fmtNum(h), attr(src))
+		}
+	case "group":
+		// Children are positioned relative to the group's centre
+		fmt.Fprintf(buf, `<g transform="translate(%s %s)">`, fmtNum(w/2), fmtNum(h/2))
+		if children, ok := o["objects"].([]interface{}); ok {
+			renderObjects(buf, children, depth+1)
+		}
+		buf.WriteString("</g>")
+	}
+
+	buf.WriteString("</g>\n")
+}
+
+func renderText(buf *bytes.Buffer, o map[string]interface{}, w float64) {
+	fontSize := number(o["fontSize"], 40)
+	lineHeight := number(o["lineHeight"], 1.16) * fontSize
+
+	anchor, x := "start", 0.0
+	switch str(o["textAlign"], "left") {
+	case "center":
+		anchor, x = "middle", w/2
+	case "right":
+		anchor, x = "end", w
+	}
+
+	fmt.Fprintf(buf, `<text font-family="%s" font-size="%s" font-weight="%s" font-style="%s" text-anchor="%s"%s>`,
+		attr(str(o["fontFamily"], "Times New Roman")), fmtNum(fontSize), attr(fmt.Sprint(valueOr(o["fontWeight"], "normal"))),
+		attr(str(o["fontStyle"], "normal")), anchor, paintAttrs(o))
+
+	for i, line := range strings.Split(str(o["text"], ""), "\n") {
+		// Fabric places the baseline at roughly 0.8 of the first line
+		y := fontSize*0.8 + float64(i)*lineHeight
+		fmt.Fprintf(buf, `<tspan x="%s" y="%s">`, fmtNum(x), fmtNum(y))
+		xml.EscapeText(buf, []byte(line))
+		buf.WriteString("</tspan>")
+	}
+	buf.WriteString("</text>")
+}
+
+func paintAttrs(o map[string]interface{}) string {
+	fill := "rgb(0,0,0)"
+	if value, exists := o["fill"]; exists {
+		fill = paint(value)
+	}
+	stroke := "none"
+	if value, exists := o["stroke"]; exists {
+		stroke = paint(value)
+	}
+	return fmt.Sprintf(` fill="%s" stroke="%s" stroke-width="%s"`, attr(fill), attr(stroke), fmtNum(number(o["strokeWidth"], 1)))
+}
+
+// paint converts a Fabric fill/stroke value. Gradients and patterns are not
+// supported and render as transparent.
+func paint(value interface{}) string {
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return "none"
+}
+
+func opacityAttr(o map[string]interface{}) string {
+	opacity := number(o["opacity"], 1)
+	if opacity == 1 {
+		return ""
+	}
+	return fmt.Sprintf(` opacity="%s"`, fmtNum(opacity))
+}
+
+func pathOffset(o map[string]interface{}) (float64, float64) {
+	offset, _ := o["pathOffset"].(map[string]interface{})
+	return number(offset["x"], 0), number(offset["y"], 0)
+}
+
+func points(value interface{}) string {
+	list, _ := value.([]interface{})
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		point, _ := item.(map[string]interface{})
+		parts = append(parts, fmtNum(number(point["x"], 0))+","+fmtNum(number(point["y"], 0)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// pathData converts Fabric's [["M", x, y], ["L", x, y], ...] into SVG path
+// syntax. A path stored as a string is passed through.
+func pathData(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	commands, _ := value.([]interface{})
+	var parts []string
+	for _, item := range commands {
+		segment, _ := item.([]interface{})
+		for _, token := range segment {
+			switch t := token.(type) {
+			case string:
+				parts = append(parts, t)
+			case float64:
+				parts = append(parts, fmtNum(t))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func originFactor(origin string) float64 {
+	switch origin {
+	case "center":
+		return 0.5
+	case "right", "bottom":
+		return 1
+	default:
+		return 0
+	}
+}
+
+func number(value interface{}, fallback float64) float64 {
+	switch n := value.(type) {
+	case float64:
+		return n
+	case string:
+		if f, err := strconv.ParseFloat(n, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func str(value interface{}, fallback string) string {
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return fallback
+}
+
+func valueOr(value interface{}, fallback interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+	return value
+}
+
+func fmtNum(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func attr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,30 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the outcome of scanning a file.
+type Result struct {
+	Infected bool
+	// Signature names what was found when Infected is set
+	Signature string
+}
+
+// Scanner checks uploaded content for malware. An error means the file
+// could not be scanned and must stay quarantined.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// NopScanner clears every file. It is used when no scanner is configured.
+type NopScanner struct{}
+
+func NewNopScanner() *NopScanner {
+	return &NopScanner{}
+}
+
+func (s *NopScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	return &Result{}, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Object is a stored file opened for reading. Callers must close Body.
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage persists uploads, thumbnails and exports under slash-separated
+// keys such as "uploads/photo.png".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that grants read access to key until ttl elapses.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// UsageReporter is implemented by backends that can cheaply total the
+// objects stored under a key prefix.
+type UsageReporter interface {
+	Usage(ctx context.Context, prefix string) (files, bytes int64, err error)
+}
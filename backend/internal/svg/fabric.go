@@ -0,0 +1,206 @@
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxFabricObjects bounds the size of a converted SVG.
+const maxFabricObjects = 500
+
+// ErrNotConvertible means an SVG uses features with no direct Fabric
+// equivalent (transforms, gradients, text, ...) and is kept as an image.
+var ErrNotConvertible = errors.New("SVG is too complex to convert into canvas objects")
+
+// Conversion is an SVG turned into Fabric objects. Positions are in the
+// SVG's user units; a client scales them by Width/Height as needed.
+type Conversion struct {
+	Width   float64                  `json:"width"`
+	Height  float64                  `json:"height"`
+	Objects []map[string]interface{} `json:"objects"`
+}
+
+// paintStyle is the fill and stroke inherited from enclosing groups.
+type paintStyle struct {
+	fill        string
+	stroke      string
+	strokeWidth float64
+	opacity     float64
+}
+
+// ToFabric converts a sanitised SVG made only of basic shapes and paths in
+// untransformed groups into Fabric objects, so they can be edited on the
+// canvas. Anything else returns ErrNotConvertible.
+func ToFabric(data []byte) (*Conversion, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	conversion := &Conversion{Objects: []map[string]interface{}{}}
+	styles := []paintStyle{{fill: "rgb(0,0,0)", stroke: "", strokeWidth: 1, opacity: 1}}
+	depth := 0
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrNotSVG
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := attrMap(t.Attr)
+			if _, ok := attrs["transform"]; ok {
+				return nil, ErrNotConvertible
+			}
+			style, err := inheritStyle(styles[len(styles)-1], attrs)
+			if err != nil {
+				return nil, err
+			}
+			styles = append(styles, style)
+			depth++
+
+			switch t.Name.Local {
+			case "svg":
+				if depth > 1 {
+					return nil, ErrNotConvertible
+				}
+				// Shapes are in viewBox units; an offset origin is not supported
+				conversion.Width, conversion.Height = dimensions(t.Attr)
+				if viewBox := numbers(attrs["viewBox"]); len(viewBox) == 4 {
+					if viewBox[0] != 0 || viewBox[1] != 0 {
+						return nil, ErrNotConvertible
+					}
+					conversion.Width, conversion.Height = viewBox[2], viewBox[3]
+				}
+			case "g", "title", "desc":
+			case "rect", "circle", "ellipse", "line", "polyline", "polygon", "path":
+				object, err := shape(t.Name.Local, attrs)
+				if err != nil {
+					return nil, err
+				}
+				applyStyle(object, style)
+				if conversion.Objects = append(conversion.Objects, object); len(conversion.Objects) > maxFabricObjects {
+					return nil, ErrNotConvertible
+				}
+			default:
+				return nil, ErrNotConvertible
+			}
+
+		case xml.EndElement:
+			styles = styles[:len(styles)-1]
+			depth--
+		}
+	}
+
+	if len(conversion.Objects) == 0 {
+		return nil, ErrNotConvertible
+	}
+	return conversion, nil
+}
+
+func shape(element string, a map[string]string) (map[string]interface{}, error) {
+	num := func(key string) float64 {
+		n, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(a[key]), "px"), 64)
+		return n
+	}
+
+	switch element {
+	case "rect":
+		return map[string]interface{}{
+			"type": "rect", "left": num("x"), "top": num("y"),
+			"width": num("width"), "height": num("height"), "rx": num("rx"), "ry": num("ry"),
+		}, nil
+	case "circle":
+		r := num("r")
+		return map[string]interface{}{"type": "circle", "left": num("cx") - r, "top": num("cy") - r, "radius": r}, nil
+	case "ellipse":
+		rx, ry := num("rx"), num("ry")
+		return map[string]interface{}{"type": "ellipse", "left": num("cx") - rx, "top": num("cy") - ry, "rx": rx, "ry": ry}, nil
+	case "line":
+		// Fabric derives the position from the endpoints
+		return map[string]interface{}{"type": "line", "x1": num("x1"), "y1": num("y1"), "x2": num("x2"), "y2": num("y2")}, nil
+	case "polyline", "polygon":
+		coords := numbers(a["points"])
+		if len(coords) < 4 || len(coords)%2 != 0 {
+			return nil, ErrNotConvertible
+		}
+		points := make([]map[string]float64, 0, len(coords)/2)
+		for i := 0; i < len(coords); i += 2 {
+			points = append(points, map[string]float64{"x": coords[i], "y": coords[i+1]})
+		}
+		return map[string]interface{}{"type": element, "points": points}, nil
+	case "path":
+		if strings.TrimSpace(a["d"]) == "" {
+			return nil, ErrNotConvertible
+		}
+		// Fabric parses the path string and computes its bounds on load
+		return map[string]interface{}{"type": "path", "path": a["d"]}, nil
+	}
+	return nil, ErrNotConvertible
+}
+
+// inheritStyle applies an element's presentation attributes and simple
+// style declarations. Paint servers (url(#...)) cannot be converted.
+func inheritStyle(parent paintStyle, attrs map[string]string) (paintStyle, error) {
+	properties := map[string]string{}
+	for _, key := range []string{"fill", "stroke", "stroke-width", "opacity"} {
+		if value, ok := attrs[key]; ok {
+			properties[key] = value
+		}
+	}
+	for _, declaration := range strings.Split(attrs["style"], ";") {
+		if key, value, ok := strings.Cut(declaration, ":"); ok {
+			properties[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	style := parent
+	for key, value := range properties {
+		value = strings.TrimSpace(value)
+		if strings.Contains(strings.ToLower(value), "url(") {
+			return style, ErrNotConvertible
+		}
+		switch key {
+		case "fill":
+			style.fill = value
+		case "stroke":
+			style.stroke = value
+		case "stroke-width":
+			style.strokeWidth = length(value)
+		case "opacity":
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				style.opacity *= n
+			}
+		}
+	}
+	return style, nil
+}
+
+func applyStyle(object map[string]interface{}, style paintStyle) {
+	fill := style.fill
+	if fill == "none" {
+		fill = ""
+	}
+	stroke := style.stroke
+	if stroke == "none" {
+		stroke = ""
+	}
+	object["fill"] = fill
+	object["stroke"] = stroke
+	object["strokeWidth"] = style.strokeWidth
+	object["opacity"] = style.opacity
+}
+
+func attrMap(attrs []xml.Attr) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		if a.Name.Space == "" {
+			m[a.Name.Local] = a.Value
+		}
+	}
+	return m
+}
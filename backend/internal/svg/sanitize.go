@@ -0,0 +1,394 @@
+// Package svg sanitises user-supplied SVG files and converts simple ones
+// into Fabric.js objects.
+package svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MimeType = "image/svg+xml"
+
+	// Limits on what Sanitize accepts
+	MaxBytes    = 2 * 1024 * 1024
+	maxElements = 10000
+	maxDepth    = 64
+	// <use> copies the element it points at, so a few nested references
+	// can expand into billions of elements when rendered
+	maxExpandedElements = 100000
+	maxUseDepth         = 8
+
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+	xmlNamespace   = "http://www.w3.org/XML/1998/namespace"
+)
+
+var (
+	ErrNotSVG      = errors.New("file is not an SVG document")
+	ErrTooLarge    = errors.New("SVG file is too large")
+	ErrTooComplex  = errors.New("SVG has too many elements or is nested too deeply")
+	ErrUnsafeInput = errors.New("SVG contains a document type definition")
+)
+
+// allowedElements are kept; anything else is dropped with its children.
+// Scripts, styles, foreignObject, animations (which can rewrite links) and
+// filters (feImage loads URLs) are deliberately absent.
+var allowedElements = map[string]bool{
+	"svg": true, "g": true, "defs": true, "symbol": true, "use": true,
+	"title": true, "desc": true,
+	"path": true, "rect": true, "circle": true, "ellipse": true,
+	"line": true, "polyline": true, "polygon": true,
+	"text": true, "tspan": true, "textPath": true,
+	"linearGradient": true, "radialGradient": true, "stop": true,
+	"clipPath": true, "mask": true, "pattern": true, "marker": true,
+	"image": true,
+}
+
+// textElements keep their character data.
+var textElements = map[string]bool{"text": true, "tspan": true, "textPath": true, "title": true, "desc": true}
+
+var (
+	urlReference  = regexp.MustCompile(`(?i)url\s*\(\s*['"]?\s*([^'")\s]*)`)
+	dataImageHref = regexp.MustCompile(`^data:image/(png|jpeg|gif|webp);base64,[A-Za-z0-9+/=\s]+$`)
+)
+
+// Info describes a sanitised SVG.
+type Info struct {
+	Width    float64
+	Height   float64
+	Elements int
+}
+
+// Detect reports whether data looks like an SVG document.
+func Detect(data []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local == "svg"
+		}
+	}
+}
+
+// Sanitize parses data and rebuilds it from allowed elements and
+// attributes only: event handlers, script and javascript: URLs, and
+// references to anything outside the document are removed. Embedded raster
+// images are kept when given as base64 data URIs.
+func Sanitize(data []byte) ([]byte, *Info, error) {
+	if len(data) > MaxBytes {
+		return nil, nil, ErrTooLarge
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	var buf bytes.Buffer
+	info := &Info{}
+	var stack []string // kept element names; "" marks a dropped subtree
+	skipDepth := 0
+	refs := newUseGraph()
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, ErrNotSVG
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) >= maxDepth {
+				return nil, nil, ErrTooComplex
+			}
+			if skipDepth > 0 || !allowedElement(t.Name) || (len(stack) == 0 && t.Name.Local != "svg") {
+				if len(stack) == 0 {
+					return nil, nil, ErrNotSVG
+				}
+				skipDepth++
+				stack = append(stack, "")
+				continue
+			}
+			if info.Elements++; info.Elements > maxElements {
+				return nil, nil, ErrTooComplex
+			}
+
+			name := t.Name.Local
+			node := refs.add()
+			buf.WriteString("<" + name)
+			if len(stack) == 0 {
+				buf.WriteString(` xmlns="` + svgNamespace + `" xmlns:xlink="` + xlinkNamespace + `"`)
+				info.Width, info.Height = dimensions(t.Attr)
+			}
+			for _, a := range t.Attr {
+				if attrName, value, ok := sanitizeAttr(name, a); ok {
+					refs.record(node, name, attrName, value)
+					buf.WriteString(" " + attrName + `="`)
+					xml.EscapeText(&buf, []byte(value))
+					buf.WriteString(`"`)
+				}
+			}
+			buf.WriteString(">")
+			stack = append(stack, name)
+			refs.open(node)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, nil, ErrNotSVG
+			}
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if name == "" {
+				skipDepth--
+				continue
+			}
+			refs.close()
+			buf.WriteString("</" + name + ">")
+
+		case xml.CharData:
+			if skipDepth == 0 && len(stack) > 0 && textElements[stack[len(stack)-1]] {
+				xml.EscapeText(&buf, t)
+			}
+
+		case xml.Directive:
+			// DOCTYPEs can declare entities; nothing legitimate needs them
+			if bytes.Contains(bytes.ToUpper(t), []byte("ENTITY")) {
+				return nil, nil, ErrUnsafeInput
+			}
+		}
+	}
+
+	if info.Elements == 0 || len(stack) != 0 {
+		return nil, nil, ErrNotSVG
+	}
+	if err := refs.check(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), info, nil
+}
+
+// useGraph records the kept elements with their ids and <use> references,
+// so the document can be sized as a renderer would expand it.
+type useGraph struct {
+	nodes []useNode
+	ids   map[string]int
+	// parents are the elements still open while parsing
+	parents []int
+}
+
+type useNode struct {
+	children []int
+	ref      string
+	// Filled in by expand: 0 unvisited, 1 in progress, 2 done
+	state  int
+	size   int
+	height int
+}
+
+func newUseGraph() *useGraph {
+	return &useGraph{ids: map[string]int{}}
+}
+
+// add creates a node as a child of the innermost open element.
+func (g *useGraph) add() int {
+	index := len(g.nodes)
+	g.nodes = append(g.nodes, useNode{})
+	if len(g.parents) > 0 {
+		parent := g.parents[len(g.parents)-1]
+		g.nodes[parent].children = append(g.nodes[parent].children, index)
+	}
+	return index
+}
+
+func (g *useGraph) open(index int) { g.parents = append(g.parents, index) }
+
+func (g *useGraph) close() { g.parents = g.parents[:len(g.parents)-1] }
+
+// record notes a kept attribute that names or references an element.
+// Renderers use the first element with a given id.
+func (g *useGraph) record(index int, element, name, value string) {
+	switch {
+	case name == "id":
+		if _, ok := g.ids[value]; !ok {
+			g.ids[value] = index
+		}
+	case element == "use" && (name == "href" || name == "xlink:href"):
+		g.nodes[index].ref = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	}
+}
+
+// check rejects documents whose <use> references are circular, chained
+// too deeply or expand to too many elements.
+func (g *useGraph) check() error {
+	if len(g.nodes) == 0 {
+		return nil
+	}
+	_, _, err := g.expand(0)
+	return err
+}
+
+// expand returns the number of elements node index renders as and the
+// longest chain of <use> references below it.
+func (g *useGraph) expand(index int) (int, int, error) {
+	node := &g.nodes[index]
+	switch node.state {
+	case 1:
+		return 0, 0, ErrTooComplex
+	case 2:
+		return node.size, node.height, nil
+	}
+	node.state = 1
+
+	size, height := 1, 0
+	for _, child := range node.children {
+		childSize, childHeight, err := g.expand(child)
+		if err != nil {
+			return 0, 0, err
+		}
+		size += childSize
+		if childHeight > height {
+			height = childHeight
+		}
+		if size > maxExpandedElements {
+			return 0, 0, ErrTooComplex
+		}
+	}
+	if target, ok := g.ids[node.ref]; ok && node.ref != "" {
+		targetSize, targetHeight, err := g.expand(target)
+		if err != nil {
+			return 0, 0, err
+		}
+		size += targetSize
+		if targetHeight+1 > height {
+			height = targetHeight + 1
+		}
+	}
+	if size > maxExpandedElements || height > maxUseDepth {
+		return 0, 0, ErrTooComplex
+	}
+
+	node.state, node.size, node.height = 2, size, height
+	return size, height, nil
+}
+
+func allowedElement(name xml.Name) bool {
+	if name.Space != "" && name.Space != svgNamespace {
+		return false
+	}
+	return allowedElements[name.Local]
+}
+
+// sanitizeAttr returns the attribute to write, or false to drop it.
+func sanitizeAttr(element string, a xml.Attr) (string, string, bool) {
+	name := a.Name.Local
+	switch a.Name.Space {
+	case "":
+	case xlinkNamespace:
+		if name != "href" {
+			return "", "", false
+		}
+		name = "xlink:href"
+	case xmlNamespace:
+		if name != "space" && name != "lang" {
+			return "", "", false
+		}
+		name = "xml:" + name
+	default:
+		// Namespace declarations and foreign attributes
+		return "", "", false
+	}
+
+	lower := strings.ToLower(a.Name.Local)
+	if strings.HasPrefix(lower, "on") || lower == "xmlns" {
+		return "", "", false
+	}
+
+	value := a.Value
+	if compact := strings.ToLower(strings.Map(dropSpace, value)); strings.Contains(compact, "javascript:") ||
+		strings.Contains(compact, "vbscript:") || strings.Contains(compact, "expression(") || strings.Contains(compact, "@import") {
+		return "", "", false
+	}
+
+	if lower == "href" {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(value), "#"):
+		case element == "image" && dataImageHref.MatchString(strings.TrimSpace(value)):
+		default:
+			return "", "", false
+		}
+	}
+
+	// url(...) may only point at elements in this document
+	for _, match := range urlReference.FindAllStringSubmatch(value, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			return "", "", false
+		}
+	}
+
+	return name, value, true
+}
+
+func dropSpace(r rune) rune {
+	if r <= ' ' {
+		return -1
+	}
+	return r
+}
+
+// dimensions reads the root's size from width/height, falling back to its
+// viewBox.
+func dimensions(attrs []xml.Attr) (float64, float64) {
+	var width, height float64
+	var viewBox []float64
+	for _, a := range attrs {
+		switch a.Name.Local {
+		case "width":
+			width = length(a.Value)
+		case "height":
+			height = length(a.Value)
+		case "viewBox":
+			viewBox = numbers(a.Value)
+		}
+	}
+	if len(viewBox) == 4 {
+		if width == 0 {
+			width = viewBox[2]
+		}
+		if height == 0 {
+			height = viewBox[3]
+		}
+	}
+	return width, height
+}
+
+// length parses a plain or px length; relative units are treated as unknown.
+func length(value string) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "px"), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func numbers(value string) []float64 {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+	result := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil
+		}
+		result = append(result, n)
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+package svg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeRemovesUnsafeContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		absent []string
+		kept   []string
+	}{
+		{
+			name:   "script element",
+			input:  `<svg><script>alert(1)</script><rect width="10"/></svg>`,
+			absent: []string{"script", "alert"},
+			kept:   []string{`<rect width="10">`},
+		},
+		{
+			name:   "event handlers",
+			input:  `<svg onload="alert(1)"><rect onclick="alert(2)" ONMOUSEOVER="alert(3)" fill="red"/></svg>`,
+			absent: []string{"onload", "onclick", "ONMOUSEOVER", "alert"},
+			kept:   []string{`fill="red"`},
+		},
+		{
+			name:   "javascript href",
+			input:  `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href="javascript:alert(1)"/><use xlink:href="java&#x09;script:alert(1)"/></svg>`,
+			absent: []string{"javascript", "alert"},
+		},
+		{
+			name:   "vbscript href",
+			input:  `<svg><use href="vbscript:msgbox(1)"/></svg>`,
+			absent: []string{"vbscript", "msgbox"},
+			kept:   []string{"<use>"},
+		},
+		{
+			name:   "external href",
+			input:  `<svg><use href="https://evil.example/sprite.svg#icon"/><image href="https://evil.example/a.png"/></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "external url()",
+			input:  `<svg><rect fill="url(https://evil.example/p.svg#p)" stroke="url( 'http://evil.example/s' )" style="fill: url(//evil.example/x)"/></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:  "local references",
+			input: `<svg><defs><linearGradient id="g"/></defs><rect id="r" fill="url(#g)"/><use href="#r"/></svg>`,
+			kept:  []string{`fill="url(#g)"`, `href="#r"`},
+		},
+		{
+			name:   "data image",
+			input:  `<svg><image href="data:image/png;base64,iVBORw0KGgo="/><image href="data:text/html;base64,PHNjcmlwdD4="/></svg>`,
+			absent: []string{"text/html"},
+			kept:   []string{"data:image/png;base64,iVBORw0KGgo="},
+		},
+		{
+			name:   "foreignObject",
+			input:  `<svg><foreignObject><body xmlns="http://www.w3.org/1999/xhtml"><iframe src="https://evil.example"/></body></foreignObject></svg>`,
+			absent: []string{"foreignObject", "iframe", "evil.example"},
+		},
+		{
+			name:   "style and animation",
+			input:  `<svg><style>@import url(https://evil.example/x.css);</style><a><set attributeName="href" to="javascript:alert(1)"/></a></svg>`,
+			absent: []string{"style", "@import", "set", "javascript"},
+		},
+		{
+			name:   "css expression",
+			input:  `<svg><rect style="width: expression(alert(1))"/></svg>`,
+			absent: []string{"expression", "alert"},
+		},
+		{
+			name:   "foreign namespaces",
+			input:  `<svg xmlns:x="http://example.com/x"><x:script/><rect x:onload="alert(1)"/></svg>`,
+			absent: []string{"script", "alert", "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, _, err := Sanitize([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Sanitize error = %v", err)
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(string(out), s) {
+					t.Errorf("output contains %q: %s", s, out)
+				}
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(string(out), s) {
+					t.Errorf("output lacks %q: %s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeRejects(t *testing.T) {
+	entities := `<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY a "aaaaaaaaaa"><!ENTITY b "&a;&a;&a;&a;&a;">]><svg><text>&b;</text></svg>`
+	external := `<!DOCTYPE svg [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><svg><text>&xxe;</text></svg>`
+
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"DTD entities", entities, ErrUnsafeInput},
+		{"external entity", external, ErrUnsafeInput},
+		{"not svg", `<html><body/></html>`, ErrNotSVG},
+		{"not xml", `not an svg`, ErrNotSVG},
+		{"unclosed", `<svg><rect>`, ErrNotSVG},
+		{"too large", `<svg>` + strings.Repeat(" ", MaxBytes) + `</svg>`, ErrTooLarge},
+		{"nested too deep", `<svg>` + strings.Repeat("<g>", maxDepth) + strings.Repeat("</g>", maxDepth) + `</svg>`, ErrTooComplex},
+		{"too many elements", `<svg>` + strings.Repeat("<rect/>", maxElements) + `</svg>`, ErrTooComplex},
+		{"use expansion", useLaughs(10, 10), ErrTooComplex},
+		{"use chain", useChain(maxUseDepth + 1), ErrTooComplex},
+		{"use cycle", `<svg><g id="a"><use href="#b"/></g><g id="b"><use href="#a"/></g></svg>`, ErrTooComplex},
+		{"use of ancestor", `<svg id="root"><g><use href="#root"/></g></svg>`, ErrTooComplex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Sanitize([]byte(tt.input)); !errors.Is(err, tt.want) {
+				t.Errorf("Sanitize error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeAcceptsWithinLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"nested", `<svg>` + strings.Repeat("<g>", maxDepth-1) + strings.Repeat("</g>", maxDepth-1) + `</svg>`},
+		{"many elements", `<svg>` + strings.Repeat("<rect/>", maxElements-1) + `</svg>`},
+		{"use expansion", useLaughs(4, 10)},
+		{"use chain", useChain(maxUseDepth)},
+		{"use of unknown id", `<svg><use href="#missing"/></svg>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Sanitize([]byte(tt.input)); err != nil {
+				t.Errorf("Sanitize error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSanitizeInfo(t *testing.T) {
+	_, info, err := Sanitize([]byte(`<svg width="120px" viewBox="0 0 300 200"><rect/><circle/></svg>`))
+	if err != nil {
+		t.Fatalf("Sanitize error = %v", err)
+	}
+	if info.Width != 120 || info.Height != 200 || info.Elements != 3 {
+		t.Errorf("Info = %+v, want 120x200 with 3 elements", info)
+	}
+}
+
+// useLaughs builds levels of groups that each use the previous one fanout
+// times, rendering as roughly fanout^levels elements.
+func useLaughs(levels, fanout int) string {
+	var b strings.Builder
+	b.WriteString(`<svg><defs><rect id="l0"/>`)
+	for level := 1; level <= levels; level++ {
+		fmt.Fprintf(&b, `<g id="l%d">`, level)
+		for i := 0; i < fanout; i++ {
+			fmt.Fprintf(&b, `<use href="#l%d"/>`, level-1)
+		}
+		b.WriteString(`</g>`)
+	}
+	fmt.Fprintf(&b, `</defs><use href="#l%d"/></svg>`, levels)
+	return b.String()
+}
+
+// useChain builds a chain of depth <use> elements, each pointing at the
+// previous one.
+func useChain(depth int) string {
+	var b strings.Builder
+	b.WriteString(`<svg><rect id="u0"/>`)
+	for i := 1; i <= depth; i++ {
+		fmt.Fprintf(&b, `<use id="u%d" href="#u%d"/>`, i, i-1)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+const apiKeyPrefix = "cdk"
+
+// GenerateAPIKey returns a new plaintext API key together with its lookup
+// prefix. Only the hash of the key is ever stored.
+func GenerateAPIKey() (key string, prefix string, err error) {
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	prefix = fmt.Sprintf("%s_%s", apiKeyPrefix, hex.EncodeToString(prefixBytes))
+	key = fmt.Sprintf("%s_%s", prefix, hex.EncodeToString(secret))
+	return key, prefix, nil
+}
+
+// HashAPIKey hashes a plaintext key for storage and lookup. Keys carry 256
+// bits of entropy, so a fast hash is sufficient.
+func HashAPIKey(key string) string {
+	return HashToken(key)
+}
@@ -11,22 +11,31 @@ import (
 type JWTClaims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	// ImpersonatorID is set when an admin is acting as UserID.
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
 func GenerateJWT(userID string) (string, error) {
+	return signJWT(JWTClaims{UserID: userID}, 24*time.Hour)
+}
+
+// GenerateImpersonationJWT issues a short-lived token that lets adminID act
+// as userID.
+func GenerateImpersonationJWT(userID, adminID string) (string, error) {
+	return signJWT(JWTClaims{UserID: userID, ImpersonatorID: adminID}, time.Hour)
+}
+
+func signJWT(claims JWTClaims, ttl time.Duration) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "your-secret-key" // Default for development
 	}
 
-	claims := JWTClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "canvas-designer",
-		},
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    "canvas-designer",
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
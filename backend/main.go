@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"canvas-designer-backend/internal/api"
+	"canvas-designer-backend/internal/config"
 	"canvas-designer-backend/internal/database"
 )
 
@@ -29,14 +30,21 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize Gin router
+	cfg := config.Load()
+
+	// Initialize Gin router. Client IPs feed the per-IP rate limits, so
+	// forwarded headers are only believed from configured proxies.
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Chunk-SHA256")
+		c.Header("Access-Control-Expose-Headers", "Retry-After")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -47,7 +55,7 @@ func main() {
 	})
 
 	// Initialize API routes
-	api.SetupSimpleRoutes(r, db.GetDB())
+	api.SetupSimpleRoutes(r, db.GetDB(), cfg)
 
 	// Start server
 	port := os.Getenv("PORT")
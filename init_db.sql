@@ -7,10 +7,67 @@ CREATE TABLE IF NOT EXISTS users (
     email VARCHAR(255) UNIQUE NOT NULL,
     password VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+    suspended_at TIMESTAMP,
+    sessions_revoked_at TIMESTAMP,
+    deletion_scheduled_at TIMESTAMP,
+    storage_quota_bytes BIGINT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create teams table (shared workspaces)
+CREATE TABLE IF NOT EXISTS teams (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create team_members table
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id)
+);
+
+-- Create team_invitations table (pending invitations by email)
+CREATE TABLE IF NOT EXISTS team_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (team_id, email)
+);
+
+-- Create design_folders table (nested folders in a personal or team workspace)
+CREATE TABLE IF NOT EXISTS design_folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES design_folders(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create design_folder_shares table (shares apply to a folder and everything below it)
+CREATE TABLE IF NOT EXISTS design_folder_shares (
+    folder_id UUID NOT NULL REFERENCES design_folders(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'viewer' CHECK (role IN ('viewer', 'editor')),
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (folder_id, user_id)
+);
+
 -- Create designs table
 CREATE TABLE IF NOT EXISTS designs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -19,10 +76,38 @@ CREATE TABLE IF NOT EXISTS designs (
     canvas_data JSONB,
     thumbnail VARCHAR(255),
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    folder_id UUID REFERENCES design_folders(id) ON DELETE SET NULL,
+    template_id UUID,
+    -- Text of the canvas's text objects, kept in step with canvas_data by the API
+    canvas_text TEXT NOT NULL DEFAULT '',
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+        setweight(to_tsvector('english', canvas_text), 'C')
+    ) STORED,
+    -- Asset hashes and ids the canvas mentions, kept in step with canvas_data by the API
+    asset_refs TEXT[] NOT NULL DEFAULT '{}',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create design_stars table (per-user favorites)
+CREATE TABLE IF NOT EXISTS design_stars (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    design_id UUID NOT NULL REFERENCES designs(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, design_id)
+);
+
+-- Create recent_designs table (when each user last opened or saved a design)
+CREATE TABLE IF NOT EXISTS recent_designs (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    design_id UUID NOT NULL REFERENCES designs(id) ON DELETE CASCADE,
+    accessed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, design_id)
+);
+
 -- Create templates table
 CREATE TABLE IF NOT EXISTS templates (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -31,9 +116,64 @@ CREATE TABLE IF NOT EXISTS templates (
     category VARCHAR(100) NOT NULL,
     thumbnail VARCHAR(255),
     canvas_data JSONB,
+    width INTEGER GENERATED ALWAYS AS (
+        CASE WHEN jsonb_typeof(canvas_data->'width') = 'number' THEN (canvas_data->>'width')::numeric::integer END
+    ) STORED,
+    height INTEGER GENERATED ALWAYS AS (
+        CASE WHEN jsonb_typeof(canvas_data->'height') = 'number' THEN (canvas_data->>'height')::numeric::integer END
+    ) STORED,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+    ) STORED,
+    owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    visibility VARCHAR(20) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'private')),
+    is_published BOOLEAN NOT NULL DEFAULT FALSE,
+    usage_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create tags table and template_tags join table
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS template_tags (
+    template_id UUID NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (template_id, tag_id)
+);
+
+-- Designs record the template they were created from. templates is created
+-- after designs, so the key is added here, once, keeping the script rerunnable.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_designs_template_id' AND conrelid = 'designs'::regclass) THEN
+        ALTER TABLE designs ADD CONSTRAINT fk_designs_template_id
+            FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE SET NULL;
+    END IF;
+END;
+$$;
+
+-- Create generation_jobs table (bulk design generation from templates)
+CREATE TABLE IF NOT EXISTS generation_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template_id UUID REFERENCES templates(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    total INTEGER NOT NULL DEFAULT 0,
+    completed INTEGER NOT NULL DEFAULT 0,
+    export VARCHAR(10),
+    design_ids UUID[] NOT NULL DEFAULT '{}',
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
 -- Create elements table (for design elements)
 CREATE TABLE IF NOT EXISTS elements (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -43,11 +183,221 @@ CREATE TABLE IF NOT EXISTS elements (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create auth_attempts table (login/register brute-force tracking)
+CREATE TABLE IF NOT EXISTS auth_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    count INTEGER NOT NULL DEFAULT 0,
+    last_attempt TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- Create audit_logs table
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    target_id VARCHAR(255),
+    ip_address VARCHAR(64),
+    -- The admin acting as actor_id, for actions taken while impersonating
+    impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    metadata JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create api_keys table (personal API keys, stored hashed)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL,
+    key_hash VARCHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create email_verifications table (pending email changes)
+CREATE TABLE IF NOT EXISTS email_verifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create asset_folders table (user asset library)
+CREATE TABLE IF NOT EXISTS asset_folders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, name)
+);
+
+-- Create assets table (uploaded files, stored under their SHA-256 hash)
+CREATE TABLE IF NOT EXISTS assets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    original_name VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    hash VARCHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    visibility VARCHAR(20) NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'private')),
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    folder_id UUID REFERENCES asset_folders(id) ON DELETE SET NULL,
+    -- Uploads stay quarantined (pending) until the malware scanner clears them
+    scan_status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (scan_status IN ('pending', 'clean', 'rejected')),
+    scan_signature VARCHAR(255),
+    -- Resumable uploads keep their original file as an "original" variant
+    keep_original BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE NULLS NOT DISTINCT (owner_id, team_id, hash)
+);
+
+-- Create asset_tags table
+CREATE TABLE IF NOT EXISTS asset_tags (
+    asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (asset_id, tag_id)
+);
+
+-- Create asset_variants table (resized renditions generated on upload)
+CREATE TABLE IF NOT EXISTS asset_variants (
+    asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    name VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    PRIMARY KEY (asset_id, name, format)
+);
+
+-- Create asset_renders table (on-demand renders cached under derived/<hash>/)
+CREATE TABLE IF NOT EXISTS asset_renders (
+    storage_key VARCHAR(255) PRIMARY KEY,
+    hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create upload_sessions table (resumable uploads; chunks are kept in storage)
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    chunk_size INTEGER NOT NULL,
+    visibility VARCHAR(20) NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'private')),
+    team_id UUID REFERENCES teams(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Create upload_chunks table
+CREATE TABLE IF NOT EXISTS upload_chunks (
+    session_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    PRIMARY KEY (session_id, chunk_index)
+);
+
+-- Create fonts table (uploaded font files, one row per face)
+CREATE TABLE IF NOT EXISTS fonts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    family VARCHAR(255) NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 400,
+    style VARCHAR(20) NOT NULL DEFAULT 'normal' CHECK (style IN ('normal', 'italic')),
+    format VARCHAR(20) NOT NULL CHECK (format IN ('truetype', 'opentype', 'woff2')),
+    original_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE NULLS NOT DISTINCT (owner_id, team_id, hash),
+    UNIQUE NULLS NOT DISTINCT (owner_id, team_id, family, weight, style)
+);
+
+-- Create brand_kits table (palettes, font pairings and text styles are JSON lists)
+CREATE TABLE IF NOT EXISTS brand_kits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    palettes JSONB NOT NULL DEFAULT '[]',
+    font_pairings JSONB NOT NULL DEFAULT '[]',
+    text_styles JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create brand_kit_logos table
+CREATE TABLE IF NOT EXISTS brand_kit_logos (
+    brand_kit_id UUID NOT NULL REFERENCES brand_kits(id) ON DELETE CASCADE,
+    asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (brand_kit_id, asset_id)
+);
+
 -- Create indexes
 CREATE INDEX IF NOT EXISTS idx_designs_user_id ON designs(user_id);
 CREATE INDEX IF NOT EXISTS idx_designs_updated_at ON designs(updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_designs_created_at ON designs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_designs_template_id ON designs(template_id);
+CREATE INDEX IF NOT EXISTS idx_designs_team_id ON designs(team_id);
+CREATE INDEX IF NOT EXISTS idx_designs_folder_id ON designs(folder_id);
+CREATE INDEX IF NOT EXISTS idx_designs_search_vector ON designs USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS idx_designs_asset_refs ON designs USING GIN(asset_refs);
+CREATE INDEX IF NOT EXISTS idx_design_folders_parent_id ON design_folders(parent_id);
+CREATE INDEX IF NOT EXISTS idx_design_folders_team_id ON design_folders(team_id);
+-- Folder names are unique among their siblings within a workspace
+CREATE UNIQUE INDEX IF NOT EXISTS idx_design_folders_personal_name ON design_folders(owner_id, parent_id, LOWER(name)) NULLS NOT DISTINCT WHERE team_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_design_folders_team_name ON design_folders(team_id, parent_id, LOWER(name)) NULLS NOT DISTINCT WHERE team_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_design_folder_shares_user_id ON design_folder_shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_recent_designs_user_accessed ON recent_designs(user_id, accessed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_templates_team_id ON templates(team_id);
+CREATE INDEX IF NOT EXISTS idx_assets_team_id ON assets(team_id);
+CREATE INDEX IF NOT EXISTS idx_fonts_team_id ON fonts(team_id);
+CREATE INDEX IF NOT EXISTS idx_brand_kits_team_id ON brand_kits(team_id);
+CREATE INDEX IF NOT EXISTS idx_team_members_user_id ON team_members(user_id);
 CREATE INDEX IF NOT EXISTS idx_templates_category ON templates(category);
+CREATE INDEX IF NOT EXISTS idx_templates_usage_count ON templates(usage_count DESC);
+CREATE INDEX IF NOT EXISTS idx_templates_created_at ON templates(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_templates_dimensions ON templates(width, height);
+CREATE INDEX IF NOT EXISTS idx_templates_search_vector ON templates USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS idx_template_tags_tag_id ON template_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_templates_is_published ON templates(is_published);
+CREATE INDEX IF NOT EXISTS idx_templates_owner_id ON templates(owner_id);
+CREATE INDEX IF NOT EXISTS idx_assets_hash ON assets(hash);
+CREATE INDEX IF NOT EXISTS idx_assets_owner_created ON assets(owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_assets_folder_id ON assets(folder_id);
+CREATE INDEX IF NOT EXISTS idx_assets_pending ON assets(created_at) WHERE scan_status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_asset_tags_tag_id ON asset_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_assets_storage_key ON assets(storage_key);
+CREATE INDEX IF NOT EXISTS idx_asset_variants_storage_key ON asset_variants(storage_key);
+CREATE INDEX IF NOT EXISTS idx_asset_renders_hash ON asset_renders(hash);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_owner_id ON upload_sessions(owner_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_fonts_storage_key ON fonts(storage_key);
+CREATE INDEX IF NOT EXISTS idx_brand_kits_owner_id ON brand_kits(owner_id);
+CREATE INDEX IF NOT EXISTS idx_brand_kit_logos_asset_id ON brand_kit_logos(asset_id);
 CREATE INDEX IF NOT EXISTS idx_elements_design_id ON elements(design_id);
+CREATE INDEX IF NOT EXISTS idx_auth_attempts_last_attempt ON auth_attempts(last_attempt);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_generation_jobs_user_id ON generation_jobs(user_id);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action, created_at DESC);
 
 -- Update timestamps function
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -65,12 +415,24 @@ CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
 CREATE TRIGGER update_designs_updated_at BEFORE UPDATE ON designs
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_templates_updated_at BEFORE UPDATE ON templates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_teams_updated_at BEFORE UPDATE ON teams
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_brand_kits_updated_at BEFORE UPDATE ON brand_kits
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_design_folders_updated_at BEFORE UPDATE ON design_folders
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 -- Insert sample templates
-INSERT INTO templates (title, description, category, thumbnail, canvas_data) VALUES
-('Social Media Post', 'Perfect for Instagram and Facebook posts', 'social', '/templates/social-media.jpg', '{"width": 800, "height": 800, "background": "#ffffff", "elements": []}'),
-('Business Card', 'Professional business card template', 'business', '/templates/business-card.jpg', '{"width": 350, "height": 200, "background": "#ffffff", "elements": []}'),
-('Poster', 'Eye-catching poster design', 'marketing', '/templates/poster.jpg', '{"width": 600, "height": 900, "background": "#f0f0f0", "elements": []}'),
-('Flyer', 'Promotional flyer template', 'marketing', '/templates/flyer.jpg', '{"width": 500, "height": 700, "background": "#ffffff", "elements": []}');
+INSERT INTO templates (title, description, category, thumbnail, canvas_data, is_published) VALUES
+('Social Media Post', 'Perfect for Instagram and Facebook posts', 'social', '/templates/social-media.jpg', '{"width": 800, "height": 800, "background": "#ffffff", "elements": []}', TRUE),
+('Business Card', 'Professional business card template', 'business', '/templates/business-card.jpg', '{"width": 350, "height": 200, "background": "#ffffff", "elements": []}', TRUE),
+('Poster', 'Eye-catching poster design', 'marketing', '/templates/poster.jpg', '{"width": 600, "height": 900, "background": "#f0f0f0", "elements": []}', TRUE),
+('Flyer', 'Promotional flyer template', 'marketing', '/templates/flyer.jpg', '{"width": 500, "height": 700, "background": "#ffffff", "elements": []}', TRUE);
 
 -- Log initialization
 \echo 'Canvas Designer database schema initialized successfully';